@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// AuditRecord は監査ログに1実行につき1行追記する実行記録です。
+type AuditRecord struct {
+	RunInfo
+	TargetDir string            `json:"target_dir"`
+	Config    map[string]string `json:"config"`
+	Files     []FileResult      `json:"files"`
+	Stats     Stats             `json:"stats"`
+	Usage     Usage             `json:"usage"`
+	ExitCode  int               `json:"exit_code"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// AppendAudit は実行記録を1行のJSONとして監査ログファイルの末尾に追記します。
+// ファイルが存在しない場合は新規に作成します。
+func AppendAudit(path string, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("JSON変換エラー: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("監査ログオープンエラー: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("書き込みエラー: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	records := []AuditRecord{
+		{
+			RunInfo:   RunInfo{RunID: "01J0000000000000000000000A"},
+			TargetDir: "in",
+			Config:    map[string]string{"keep-going": "false"},
+			Files:     []FileResult{{Path: "in/a.csv", Status: FileReplaced, ReplaceCount: 2}},
+			Stats:     Stats{FilesProcessed: 1, FilesReplaced: 1, RowsRead: 3, RowsReplaced: 2},
+			ExitCode:  1,
+		},
+		{
+			RunInfo:   RunInfo{RunID: "01J0000000000000000000000B"},
+			TargetDir: "in",
+			Files:     []FileResult{{Path: "in/a.csv", Status: FileFailed, Error: "ファイルオープンエラー"}},
+			ExitCode:  2,
+			Error:     "ファイルオープンエラー",
+		},
+	}
+	for _, r := range records {
+		if err := AppendAudit(path, r); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("監査ログが作成されていません: %v", err)
+	}
+	defer f.Close()
+
+	var got []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("1行1レコードのJSONではありません: %v", err)
+		}
+		got = append(got, r)
+	}
+
+	if len(got) != len(records) {
+		t.Fatalf("レコード数 = %d, want %d", len(got), len(records))
+	}
+	for i := range records {
+		if got[i].RunID != records[i].RunID || got[i].ExitCode != records[i].ExitCode || got[i].Files[0] != records[i].Files[0] {
+			t.Errorf("レコード%d = %+v, want %+v", i, got[i], records[i])
+		}
+	}
+}
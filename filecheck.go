@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// seqNamePattern はファイル名(拡張子除く)末尾の連番とその前の接頭辞をキャプチャします。
+var seqNamePattern = regexp.MustCompile(`^(.*?)(\d+)$`)
+
+// ReadListFile は1行1要素のテキストファイルを読み込みます。
+// 空行と `#` で始まるコメント行は無視し、先頭のBOMは取り除きます。
+func ReadListFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("一覧ファイルオープンエラー: %w", err)
+	}
+	defer f.Close()
+
+	// 空の一覧も「何も想定しない」という指定として扱えるよう、nil ではなく空のスライスを返す
+	items := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("一覧ファイル読み込みエラー: %w", err)
+	}
+	return items, nil
+}
+
+// ReadFileManifest は処理対象ファイルの一覧を読み込みます。
+// 相対パスは一覧ファイルのあるディレクトリを基準に解決します。
+func ReadFileManifest(path string) ([]string, error) {
+	items, err := ReadListFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := filepath.Dir(path)
+	for i, item := range items {
+		if !filepath.IsAbs(item) {
+			items[i] = filepath.Join(baseDir, item)
+		}
+	}
+	return items, nil
+}
+
+// checkExpectedFiles はディレクトリ内のCSVファイル名と想定ファイル一覧を突き合わせ、
+// 不足しているファイルと想定外のファイルをまとめてエラーとして返します。
+func checkExpectedFiles(names, expected []string) error {
+	found := make(map[string]bool, len(names))
+	for _, name := range names {
+		found[name] = true
+	}
+	want := make(map[string]bool, len(expected))
+	var missing, extra []string
+	for _, name := range expected {
+		if want[name] {
+			continue
+		}
+		want[name] = true
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range names {
+		if !want[name] {
+			extra = append(extra, name)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("想定ファイルが存在しません: %s", strings.Join(missing, ", ")))
+	}
+	if len(extra) > 0 {
+		errs = append(errs, fmt.Errorf("想定外のファイルが存在します: %s", strings.Join(extra, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// checkSequence はファイル名末尾の連番を接頭辞ごとに集計し、
+// 欠番と番号の重複をまとめてエラーとして返します。連番を持たないファイルは対象外です。
+func checkSequence(names []string) error {
+	groups := make(map[string]map[int][]string)
+	for _, name := range names {
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		submatches := seqNamePattern.FindStringSubmatch(base)
+		if submatches == nil {
+			continue
+		}
+		num, err := strconv.Atoi(submatches[2])
+		if err != nil {
+			continue // 桁あふれするような番号は連番とみなさない
+		}
+		prefix := submatches[1]
+		if groups[prefix] == nil {
+			groups[prefix] = make(map[int][]string)
+		}
+		groups[prefix][num] = append(groups[prefix][num], name)
+	}
+
+	prefixes := make([]string, 0, len(groups))
+	for prefix := range groups {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+
+	var errs []error
+	for _, prefix := range prefixes {
+		nums := make([]int, 0, len(groups[prefix]))
+		for num := range groups[prefix] {
+			nums = append(nums, num)
+		}
+		slices.Sort(nums)
+
+		var gaps []string
+		for i := 1; i < len(nums); i++ {
+			from, to := nums[i-1]+1, nums[i]-1
+			switch {
+			case from == to:
+				gaps = append(gaps, strconv.Itoa(from))
+			case from < to:
+				gaps = append(gaps, fmt.Sprintf("%d-%d", from, to))
+			}
+		}
+		if len(gaps) > 0 {
+			errs = append(errs, fmt.Errorf("接頭辞 %q に欠番があります: %s", prefix, strings.Join(gaps, ", ")))
+		}
+
+		for _, num := range nums {
+			if dup := groups[prefix][num]; len(dup) > 1 {
+				errs = append(errs, fmt.Errorf("接頭辞 %q の番号 %d が重複しています: %s", prefix, num, strings.Join(dup, ", ")))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expect.txt")
+	content := "\uFEFFINS_01.csv\r\n\r\n# コメント\r\n  UPD_01.csv  \r\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	got, err := ReadListFile(path)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	want := []string{"INS_01.csv", "UPD_01.csv"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %v, want %v", got, want)
+	}
+}
+
+func TestReadFileManifest(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "data", "UPD_01.csv")
+	path := filepath.Join(dir, "manifest.txt")
+	content := "INS_01.csv\r\n" + abs + "\r\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	got, err := ReadFileManifest(path)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	want := []string{filepath.Join(dir, "INS_01.csv"), abs}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %v, want %v", got, want)
+	}
+}
+
+func TestCheckExpectedFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		expected  []string
+		wantErr   bool
+		wantInErr []string
+	}{
+		{"完全一致", []string{"INS_01.csv", "UPD_01.csv"}, []string{"UPD_01.csv", "INS_01.csv"}, false, nil},
+		{"不足あり", []string{"INS_01.csv"}, []string{"INS_01.csv", "UPD_03.csv"}, true, []string{"存在しません: UPD_03.csv"}},
+		{"余分あり", []string{"INS_01.csv", "OLD.csv"}, []string{"INS_01.csv"}, true, []string{"想定外のファイルが存在します: OLD.csv"}},
+		{"不足と余分の両方", []string{"A.csv"}, []string{"B.csv"}, true, []string{"存在しません: B.csv", "存在します: A.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExpectedFiles(tt.names, tt.expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantInErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("エラーメッセージ %q に %q が含まれていません", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSequence(t *testing.T) {
+	tests := []struct {
+		name      string
+		names     []string
+		wantErr   bool
+		wantInErr []string
+	}{
+		{"連番が揃っている", []string{"INS_001.csv", "INS_002.csv", "INS_003.csv"}, false, nil},
+		{"接頭辞ごとに独立して判定", []string{"INS_001.csv", "INS_002.csv", "UPD_005.csv"}, false, nil},
+		{"連番を持たないファイルは対象外", []string{"INS_001.csv", "readme.csv"}, false, nil},
+		{"欠番1件", []string{"INS_001.csv", "INS_003.csv"}, true, []string{`"INS_" に欠番があります: 2`}},
+		{"欠番が連続", []string{"INS_001.csv", "INS_005.csv"}, true, []string{"欠番があります: 2-4"}},
+		{"番号の重複", []string{"INS_1.csv", "INS_001.csv"}, true, []string{"番号 1 が重複しています: INS_1.csv, INS_001.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSequence(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantInErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("エラーメッセージ %q に %q が含まれていません", err.Error(), want)
+				}
+			}
+		})
+	}
+}
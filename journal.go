@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JournalEntry は処理済みファイルの識別情報です。
+type JournalEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+	Output  string    `json:"output,omitempty"` // 出力ファイルの絶対パス（置換対象がなく出力しなかった場合は空）
+}
+
+// NewJournalEntry はファイルの識別情報を作成します。outputPath は出力したファイルのパスで、
+// 出力しなかった場合は空文字列を指定します。
+func NewJournalEntry(path string, info fs.FileInfo, outputPath string) (JournalEntry, error) {
+	sum, err := hashFile(path)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	entry := JournalEntry{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+	if outputPath != "" {
+		if entry.Output, err = filepath.Abs(outputPath); err != nil {
+			return JournalEntry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// Journal は処理済みファイルの記録です。差分処理で前回から変更のないファイルを判定するために使用します。
+// キーはファイルの絶対パスです。
+type Journal struct {
+	path    string
+	Entries map[string]JournalEntry
+}
+
+// LoadJournal は記録ファイルを読み込みます。ファイルが存在しない場合は空の記録を返します。
+func LoadJournal(path string) (*Journal, error) {
+	j := &Journal{path: path, Entries: make(map[string]JournalEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("処理記録の読み込みエラー: %w", err)
+	}
+	if err := json.Unmarshal(data, &j.Entries); err != nil {
+		return nil, fmt.Errorf("処理記録の形式エラー: %w", err)
+	}
+	return j, nil
+}
+
+// Unchanged は前回の記録からファイルが変更されていないかを判定します。
+// サイズと更新日時が一致した場合のみ、内容のハッシュを計算して比較します。
+// 前回出力したファイルが削除されている場合は、再作成できるよう変更ありとみなします。
+func (j *Journal) Unchanged(path string, info fs.FileInfo) (bool, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := j.Entries[key]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return false, nil
+	}
+	if entry.Output != "" {
+		if _, err := os.Stat(entry.Output); errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return false, err
+	}
+	return sum == entry.SHA256, nil
+}
+
+// Record はファイルを処理済みとして記録します。
+func (j *Journal) Record(path string, entry JournalEntry) error {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	j.Entries[key] = entry
+	return nil
+}
+
+// Save は記録をファイルに書き出します。途中で中断しても既存の記録が壊れないよう、
+// 一時ファイルに書き込んでから置き換えます。記録が際限なく増えないよう、
+// 既に存在しないファイルの記録は書き出す前に削除します。
+func (j *Journal) Save() error {
+	for key := range j.Entries {
+		if _, err := os.Stat(key); errors.Is(err, fs.ErrNotExist) {
+			delete(j.Entries, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(j.Entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON変換エラー: %w", err)
+	}
+
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("処理記録の書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("処理記録の置き換えエラー: %w", err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
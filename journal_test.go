@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal.json")
+	csvPath := filepath.Join(dir, "a.csv")
+	mtime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	writeCSV := func(t *testing.T, content string) os.FileInfo {
+		if err := os.WriteFile(csvPath, []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		if err := os.Chtimes(csvPath, mtime, mtime); err != nil {
+			t.Fatalf("更新日時の変更に失敗: %v", err)
+		}
+		info, err := os.Stat(csvPath)
+		if err != nil {
+			t.Fatalf("ファイル情報の取得に失敗: %v", err)
+		}
+		return info
+	}
+
+	info := writeCSV(t, "abc")
+
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("記録ファイルがなくてもエラーにならないはずです: %v", err)
+	}
+	if unchanged, err := j.Unchanged(csvPath, info); err != nil || unchanged {
+		t.Errorf("未記録のファイル: unchanged = %v, err = %v", unchanged, err)
+	}
+	entry, err := NewJournalEntry(csvPath, info, "")
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if err := j.Record(csvPath, entry); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if err := j.Save(); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	// 保存した記録を読み直して判定する
+	j, err = LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if unchanged, err := j.Unchanged(csvPath, info); err != nil || !unchanged {
+		t.Errorf("変更のないファイル: unchanged = %v, err = %v", unchanged, err)
+	}
+
+	// サイズと更新日時が同じでも内容が異なれば変更ありとみなす
+	info = writeCSV(t, "xyz")
+	if unchanged, err := j.Unchanged(csvPath, info); err != nil || unchanged {
+		t.Errorf("内容が変わったファイル: unchanged = %v, err = %v", unchanged, err)
+	}
+}
+
+func TestJournalOutput(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "a.csv")
+	outPath := filepath.Join(dir, "a.cs_")
+	for _, path := range []string{csvPath, outPath} {
+		if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	info, err := os.Stat(csvPath)
+	if err != nil {
+		t.Fatalf("ファイル情報の取得に失敗: %v", err)
+	}
+
+	j, err := LoadJournal(filepath.Join(dir, "journal.json"))
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	entry, err := NewJournalEntry(csvPath, info, outPath)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if err := j.Record(csvPath, entry); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if unchanged, err := j.Unchanged(csvPath, info); err != nil || !unchanged {
+		t.Errorf("出力ファイルがある場合: unchanged = %v, err = %v", unchanged, err)
+	}
+
+	// 出力ファイルが削除されていれば再作成のため変更ありとみなす
+	if err := os.Remove(outPath); err != nil {
+		t.Fatalf("出力ファイルの削除に失敗: %v", err)
+	}
+	if unchanged, err := j.Unchanged(csvPath, info); err != nil || unchanged {
+		t.Errorf("出力ファイルがない場合: unchanged = %v, err = %v", unchanged, err)
+	}
+}
+
+func TestJournalSavePrunes(t *testing.T) {
+	dir := t.TempDir()
+	journalPath := filepath.Join(dir, "journal.json")
+	keep := filepath.Join(dir, "keep.csv")
+	if err := os.WriteFile(keep, []byte("abc"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	gone := filepath.Join(dir, "gone.csv")
+
+	j, err := LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	for _, path := range []string{keep, gone} {
+		if err := j.Record(path, JournalEntry{Size: 3}); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+	}
+	if err := j.Save(); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	j, err = LoadJournal(journalPath)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if _, ok := j.Entries[keep]; !ok {
+		t.Errorf("存在するファイルの記録が削除されています")
+	}
+	if _, ok := j.Entries[gone]; ok {
+		t.Errorf("存在しないファイルの記録が残っています")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -13,6 +14,52 @@ var dateTimePattern = regexp.MustCompile(`"(\d{4}-\d{2}-\d{2})","([2-4][0-9]):`)
 
 const dateFormat = "2006-01-02"
 
+// hasOvertimeCandidate は行内に dateTimePattern に一致し、かつ時間が 24〜47 となる箇所が
+// 存在し得るかをバイト列のまま判定します。false の場合、ReplaceTime は何も置換しません。
+// 大半を占める置換対象のない行で、文字列への変換と正規表現の評価を省くために使用します。
+func hasOvertimeCandidate(line []byte) bool {
+	for i := 0; ; {
+		j := bytes.Index(line[i:], []byte(`","`))
+		if j < 0 {
+			return false
+		}
+		i += j
+		if isDateTimeAt(line, i) {
+			return true
+		}
+		i++
+	}
+}
+
+// isDateTimeAt は line[sep:] が `","` で始まり、その前後が `"YYYY-MM-DD","HH:`
+// (HH は 24〜47) の形式になっているかを判定します。
+func isDateTimeAt(line []byte, sep int) bool {
+	const dateLen = len(dateFormat)
+	if sep < dateLen+1 || sep+6 > len(line) || line[sep-dateLen-1] != '"' {
+		return false
+	}
+	date := line[sep-dateLen : sep]
+	for k, c := range date {
+		if k == 4 || k == 7 {
+			if c != '-' {
+				return false
+			}
+		} else if !isDigit(c) {
+			return false
+		}
+	}
+	h1, h2 := line[sep+3], line[sep+4]
+	if !isDigit(h1) || !isDigit(h2) || line[sep+5] != ':' {
+		return false
+	}
+	hour := int(h1-'0')*10 + int(h2-'0')
+	return hour >= 24 && hour <= 47
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
 // ReplaceTime はテキスト内の日付と時間を検証し、時間が 24〜47 の場合に
 // 日付を1日加算、時間を -24 してゼロ埋め置換します。
 func ReplaceTime(input string) (string, bool) {
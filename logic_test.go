@@ -65,3 +65,37 @@ func TestReplaceTime(t *testing.T) {
 		})
 	}
 }
+
+func TestHasOvertimeCandidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"対象の時間", `"2000-01-01","24:"`, true},
+		{"上限の時間", `"2000-01-01","47:"`, true},
+		{"行の途中にある場合", `1,"x","2000-01-01","30:",abc`, true},
+		{"2箇所目が対象", `"2000-01-01","12:","2000-01-01","25:"`, true},
+		{"存在しない日付も候補とする", `"2023-13-45","24:"`, true},
+		{"23時以下", `"2000-01-01","23:"`, false},
+		{"48時以上", `"2000-01-01","48:"`, false},
+		{"日付の形式が異なる", `"2000/01/01","24:"`, false},
+		{"日付の前に引用符がない", `2000-01-01","24:"`, false},
+		{"コロンがない", `"2000-01-01","24"`, false},
+		{"行末で途切れている", `"2000-01-01","2`, false},
+		{"区切りのみ", `","`, false},
+		{"空行", ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasOvertimeCandidate([]byte(tt.input)); got != tt.want {
+				t.Errorf("hasOvertimeCandidate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			// 候補なしと判定した行を ReplaceTime が置換してはならない
+			if _, replaced := ReplaceTime(tt.input); replaced && !tt.want {
+				t.Errorf("ReplaceTime が置換する行を候補なしと判定しています: %q", tt.input)
+			}
+		})
+	}
+}
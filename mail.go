@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// mailOptions は失敗時のメール通知の設定です。
+type mailOptions struct {
+	smtpAddr string // SMTPサーバーのアドレス（例: smtp.example.com:587）
+	from     string // 送信元アドレス
+	to       string // 送信先アドレス（カンマ区切り）
+	user     string // SMTP認証のユーザー名（空の場合は認証しない）
+	password string // SMTP認証のパスワード
+}
+
+// recipients は送信先アドレスの一覧を返します。
+func (m mailOptions) recipients() []string {
+	var to []string
+	for _, addr := range strings.Split(m.to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// needsMail は実行結果がメール通知の対象（異常終了、または失敗したファイルがある）であるかを判定します。
+func needsMail(exitCode int, stats Stats) bool {
+	return exitCode == 2 || stats.FilesFailed > 0
+}
+
+// MailRunResult は実行記録を obudate-<run_id>.json として添付し、実行結果をメールで通知します。
+func MailRunResult(opts mailOptions, result RunResult, record AuditRecord) error {
+	report, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON変換エラー: %w", err)
+	}
+	return SendMail(opts, result, "obudate-"+record.RunID+".json", report)
+}
+
+// SendMail は実行結果の要約を本文とし、実行記録（report）を reportName のJSONファイルとして添付したメールを送信します。
+// user が指定されている場合は PLAIN 認証を行います（net/smtp の制約により、TLS接続またはlocalhost宛ての場合のみ）。
+func SendMail(opts mailOptions, result RunResult, reportName string, report []byte) error {
+	to := opts.recipients()
+	msg, err := buildMail(opts.from, to, result, reportName, report)
+	if err != nil {
+		return fmt.Errorf("メール作成エラー: %w", err)
+	}
+
+	var auth smtp.Auth
+	if opts.user != "" {
+		host, _, err := net.SplitHostPort(opts.smtpAddr)
+		if err != nil {
+			return fmt.Errorf("SMTPサーバーのアドレスが不正です: %w", err)
+		}
+		auth = smtp.PlainAuth("", opts.user, opts.password, host)
+	}
+	if err := smtp.SendMail(opts.smtpAddr, auth, opts.from, to, msg); err != nil {
+		return fmt.Errorf("メール送信エラー: %w", err)
+	}
+	return nil
+}
+
+// buildMail は本文と添付ファイルからなる multipart/mixed 形式のメールを組み立てます。
+// 件名と本文は日本語を含むため、UTF-8 を Base64 で符号化します。
+func buildMail(from string, to []string, result RunResult, reportName string, report []byte) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	textPart, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := textPart.Write(encodeBase64Lines([]byte(mailText(result)))); err != nil {
+		return nil, err
+	}
+
+	filePart, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": reportName})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := filePart.Write(encodeBase64Lines(report)); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", result.Text))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// mailText は実行結果の要約をメール本文用のテキストにします。
+func mailText(result RunResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\r\n\r\n", result.Text)
+	fmt.Fprintf(&b, "処理対象: %s\r\n", result.TargetDir)
+	fmt.Fprintf(&b, "終了コード: %d\r\n", result.ExitCode)
+	if result.Error != "" {
+		fmt.Fprintf(&b, "エラー: %s\r\n", result.Error)
+	}
+	s := result.Stats
+	fmt.Fprintf(&b, "処理: %d件 / 置換: %d件 / 失敗: %d件 / 保留: %d件 / スキップ: %d件\r\n",
+		s.FilesProcessed, s.FilesReplaced, s.FilesFailed, s.FilesDeferred, s.FilesSkipped)
+	b.WriteString("\r\nファイルごとの結果は添付の実行記録を参照してください。\r\n")
+	return b.String()
+}
+
+// encodeBase64Lines は data を Base64 で符号化し、メールの行長制限に収まるよう76文字ごとに改行します。
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b bytes.Buffer
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
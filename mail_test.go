@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer は1通のメールを受け付けて DATA の内容を返す、テスト用の最小限のSMTPサーバーです。
+func fakeSMTPServer(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("待ち受けに失敗: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				reply("250 OK")
+			case "DATA":
+				reply("354 Start mail input")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(l, "."))
+				}
+				ch <- data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestSendMail(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	opts := mailOptions{smtpAddr: addr, from: "obudate@example.com", to: "ops@example.com, dev@example.com"}
+	result := NewRunResult("in", Stats{FilesProcessed: 1, FilesFailed: 1}, 2, nil)
+	result.Error = "ファイル処理エラー"
+
+	if err := SendMail(opts, result, "obudate-01J0.json", []byte(`{"exit_code":2}`)); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-received))
+	if err != nil {
+		t.Fatalf("メールの解析に失敗: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != result.Text {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, result.Text)
+	}
+	if got := msg.Header.Get("To"); got != "ops@example.com, dev@example.com" {
+		t.Errorf("To = %q", got)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Content-Type の解析に失敗: %v", err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	var filename string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("パートの解析に失敗: %v", err)
+		}
+		decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			t.Fatalf("Base64の復号に失敗: %v", err)
+		}
+		if part.FileName() != "" {
+			filename = part.FileName()
+		}
+		parts = append(parts, string(decoded))
+	}
+	if len(parts) != 2 {
+		t.Fatalf("パート数 = %d, want 2", len(parts))
+	}
+	if !strings.Contains(parts[0], "失敗: 1件") || !strings.Contains(parts[0], "ファイル処理エラー") {
+		t.Errorf("本文 = %q, 件数とエラーを含むべきです", parts[0])
+	}
+	if filename != "obudate-01J0.json" || parts[1] != `{"exit_code":2}` {
+		t.Errorf("添付ファイル = %s %q", filename, parts[1])
+	}
+}
+
+func TestNeedsMail(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode int
+		stats    Stats
+		want     bool
+	}{
+		{"置換あり", 1, Stats{FilesReplaced: 1}, false},
+		{"置換なし", 0, Stats{}, false},
+		{"異常終了", 2, Stats{}, true},
+		{"失敗したファイルがある", 1, Stats{FilesReplaced: 1, FilesFailed: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsMail(tt.exitCode, tt.stats); got != tt.want {
+				t.Errorf("needsMail(%d, %+v) = %v, want %v", tt.exitCode, tt.stats, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,56 +1,359 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 )
 
+// options はコマンドライン引数で指定された設定値を保持します。
+type options struct {
+	verbose    bool
+	expectPath string
+	checkSeq   bool
+	webhookURL string
+	mail       mailOptions
+	statsJSON  string
+	auditPath  string
+	filesPath  string
+	singleFile string
+	filePaths  []string
+	streamList bool
+	maxLine    int
+	showPath   PathStyle
+	journal    string
+	profile    profileOptions
+	batch      bool
+	keepGoing  bool
+	retries    int
+	retryDelay time.Duration
+	minAge     time.Duration
+	since      time.Time
+	until      time.Time
+	doneDir    string
+	errorDir   string
+	targetDir  string
+}
+
 func main() {
-	var verbose bool
-	flag.BoolVar(&verbose, "v", false, "詳細ログを表示する")
-	flag.BoolVar(&verbose, "verbose", false, "詳細ログを表示する")
+	var opts options
+	flag.BoolVar(&opts.verbose, "v", false, "詳細ログを表示する")
+	flag.BoolVar(&opts.verbose, "verbose", false, "詳細ログを表示する")
+	flag.StringVar(&opts.expectPath, "expect", "", "想定ファイル一覧のパス（1行1ファイル名）")
+	flag.BoolVar(&opts.checkSeq, "check-seq", false, "ファイル名末尾の連番の欠番・重複を検証する")
+	flag.StringVar(&opts.webhookURL, "webhook", "", "処理終了時に結果をPOSTするWebhookのURL")
+	flag.StringVar(&opts.mail.to, "mail-to", "", "異常終了時や失敗したファイルがある場合に結果をメールで通知する送信先（カンマ区切り）")
+	flag.StringVar(&opts.mail.from, "mail-from", "", "メール通知の送信元アドレス")
+	flag.StringVar(&opts.mail.smtpAddr, "smtp", "", "メール通知に使用するSMTPサーバーのアドレス（例: smtp.example.com:587）")
+	flag.StringVar(&opts.mail.user, "smtp-user", "", "SMTP認証のユーザー名。省略時は認証しない")
+	flag.StringVar(&opts.mail.password, "smtp-password", "", "SMTP認証のパスワード（環境変数での指定を推奨）")
+	flag.StringVar(&opts.singleFile, "file", "", "指定した1ファイルのみを処理する。指定時はディレクトリを走査しない")
+	flag.StringVar(&opts.filesPath, "files", "", "処理対象ファイルの一覧のパス（1行1パス）。指定時はディレクトリを走査しない")
+	flag.BoolVar(&opts.streamList, "stream-list", false, "ディレクトリ一覧を少しずつ取得しながら処理を始める（大量ファイル向け）")
+	flag.IntVar(&opts.maxLine, "max-line-size", DefaultMaxLineSize, "1行あたりの最大バイト数。超える行を含むファイルはエラーとする")
+	flag.Var(&opts.showPath, "show-path", "ログに出力するファイルパスの表記（relative, absolute, base）。省略時は指定されたパスのまま")
+	flag.BoolVar(&opts.batch, "batch", false, "<target_dir> 直下の各サブディレクトリを個別に処理し、一覧表を出力する")
+	flag.BoolVar(&opts.keepGoing, "keep-going", false, "ファイル単位のエラーが発生しても残りのファイルの処理を継続する")
+	flag.IntVar(&opts.retries, "retry", 0, "ファイルのオープン・読み込み失敗時の再試行回数")
+	flag.DurationVar(&opts.retryDelay, "retry-delay", time.Second, "再試行までの初回待機時間（以降は倍々に延長）")
+	flag.DurationVar(&opts.minAge, "min-age", 0, "最終更新からこの時間が経過していないファイルは処理を保留する（例: 2m）。置換がなく保留したファイルがある場合は終了コード3")
+	flag.Var(timeBoundValue{&opts.since}, "since", "最終更新日時がこれ以降のファイルのみ処理する（RFC3339、2006-01-02 または 24h など）")
+	flag.Var(timeBoundValue{&opts.until}, "until", "最終更新日時がこれ以前のファイルのみ処理する（RFC3339、2006-01-02 または 24h など）")
+	flag.StringVar(&opts.journal, "incremental", "", "処理記録ファイルのパス。前回から変更のないファイルをスキップし、処理したファイルを記録する")
+	flag.StringVar(&opts.doneDir, "done-dir", "", "正常に処理を終えた入力ファイルの移動先ディレクトリ。実行ごとに <run_id> のサブディレクトリへ移動する")
+	flag.StringVar(&opts.errorDir, "error-dir", "", "処理に失敗した入力ファイルの移動先ディレクトリ。実行ごとに <run_id> のサブディレクトリへ移動する")
+	flag.StringVar(&opts.profile.pprofAddr, "pprof", "", "実行中に /debug/pprof/ を公開するアドレス（例: localhost:6060）。ホストを省略した場合は localhost で待ち受ける")
+	flag.StringVar(&opts.profile.cpuProfile, "cpuprofile", "", "CPUプロファイルの出力先")
+	flag.StringVar(&opts.profile.memProfile, "memprofile", "", "終了時のヒーププロファイルの出力先")
+	flag.StringVar(&opts.statsJSON, "stats-json", "", "処理件数を1行のJSONで出力するファイルのパス（- で標準エラー出力）")
+	flag.StringVar(&opts.auditPath, "audit", "", "実行記録を1行のJSONで追記する監査ログのパス")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <target_dir>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] <csv_file>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] -file <csv_file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] -files <list_file>\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n各オプションは環境変数 %s<オプション名> でも指定できます（例: %s）。\n", envPrefix, envName("check-seq"))
+		fmt.Fprintf(os.Stderr, "<target_dir> を省略した場合は環境変数 %s を使用します。\n", envDirName)
+		fmt.Fprintln(os.Stderr, "\n終了コード:")
+		fmt.Fprintln(os.Stderr, "  0  置換対象のデータはなかった")
+		fmt.Fprintln(os.Stderr, "  1  置換あり（.cs_ を出力した）")
+		fmt.Fprintln(os.Stderr, "  2  異常終了")
+		fmt.Fprintln(os.Stderr, "  3  置換はなかったが、-min-age により保留して未確認のファイルがある")
 	}
+	// 環境変数の誤りも、Webhook・監査ログの設定を確定させてから報告する
+	envErr := applyEnvDefaults(flag.CommandLine, os.LookupEnv)
 	flag.Parse()
 
+	startedAt := time.Now()
+	info := RunInfo{
+		RunID:     newRunID(startedAt),
+		Version:   buildVersion(),
+		StartedAt: startedAt,
+	}
+	if envErr != nil {
+		fmt.Fprintf(os.Stderr, "エラー: %v\n", envErr)
+		exitEarly(opts, info, envErr)
+	}
+
 	args := flag.Args()
-	if len(args) < 1 {
+	switch {
+	case len(args) >= 1:
+		opts.targetDir, opts.filePaths = classifyArgs(args)
+	case os.Getenv(envDirName) != "":
+		opts.targetDir = os.Getenv(envDirName)
+	case opts.filesPath != "" || opts.singleFile != "":
+		// ファイルを直接指定する場合、ディレクトリは不要
+	default:
 		fmt.Fprintln(os.Stderr, "エラー: 処理対象のディレクトリパスを指定してください。")
 		flag.Usage()
-		os.Exit(2)
+		exitEarly(opts, info, errors.New("処理対象のディレクトリパスが指定されていません"))
+	}
+	if err := validateOptions(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "エラー: 設定に問題があります。")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "  - %s\n", line)
+		}
+		exitEarly(opts, info, err)
 	}
-	targetDir := args[0]
 
 	logLevel := slog.LevelInfo
-	if verbose {
+	if opts.verbose {
 		logLevel = slog.LevelDebug
 	}
+
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
-	}))
+	})).With("run_id", info.RunID)
+
+	config := effectiveConfig(flag.CommandLine)
+	logger.Info("処理を開始します",
+		"version", info.Version,
+		"target_dir", formatPath(opts.showPath, opts.targetDir),
+		"config", config,
+	)
 
 	processor := &Processor{
-		Logger: logger,
+		Logger:        logger,
+		CheckSequence: opts.checkSeq,
+		KeepGoing:     opts.keepGoing,
+		Retries:       opts.retries,
+		RetryDelay:    opts.retryDelay,
+		MinAge:        opts.minAge,
+		Since:         opts.since,
+		Until:         opts.until,
+		DoneDir:       opts.doneDir,
+		ErrorDir:      opts.errorDir,
+		RunID:         info.RunID,
+		StreamListing: opts.streamList,
+		MaxLineSize:   opts.maxLine,
+		ShowPath:      opts.showPath,
+	}
+
+	stopProfiling, err := startProfiling(opts.profile, logger)
+	if err != nil {
+		logger.Error("プロファイリングを開始できませんでした", "error", err)
+		exitEarly(opts, info, err)
+	}
+
+	exitCode, runErr := run(opts, processor)
+
+	if err := stopProfiling(); err != nil {
+		logger.Warn("プロファイルの出力に失敗しました", "error", err)
 	}
 
-	logger.Debug("処理を開始します", "target_dir", targetDir)
+	info.FinishedAt = time.Now()
+	usage := collectUsage(info.StartedAt, info.FinishedAt)
+	logger.Info("処理を終了します",
+		"exit_code", exitCode,
+		"started_at", info.StartedAt,
+		"finished_at", info.FinishedAt,
+		"elapsed", info.FinishedAt.Sub(info.StartedAt),
+		"peak_rss_bytes", usage.PeakRSSBytes,
+		"total_alloc_bytes", usage.TotalAllocBytes,
+	)
 
-	anyReplaced, err := processor.ProcessDirectory(targetDir)
+	if opts.statsJSON != "" {
+		record := StatsRecord{RunInfo: info, Stats: processor.Stats, Usage: usage, ExitCode: exitCode}
+		if err := WriteStatsJSON(opts.statsJSON, record); err != nil {
+			logger.Warn("集計結果のJSON出力に失敗しました", "path", opts.statsJSON, "error", err)
+		}
+	}
+
+	record := AuditRecord{
+		RunInfo:   info,
+		TargetDir: opts.targetDir,
+		Config:    config,
+		Files:     processor.Results,
+		Stats:     processor.Stats,
+		Usage:     usage,
+		ExitCode:  exitCode,
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	if opts.auditPath != "" {
+		if err := AppendAudit(opts.auditPath, record); err != nil {
+			logger.Warn("監査ログの追記に失敗しました", "path", opts.auditPath, "error", err)
+		}
+	}
+
+	result := NewRunResult(opts.targetDir, processor.Stats, exitCode, runErr)
+	if opts.webhookURL != "" {
+		if err := PostWebhook(opts.webhookURL, result); err != nil {
+			logger.Warn("Webhook通知に失敗しました", "url", redactURL(opts.webhookURL), "error", err)
+		}
+	}
+	if opts.mail.to != "" && needsMail(exitCode, processor.Stats) {
+		if err := MailRunResult(opts.mail, result, record); err != nil {
+			logger.Warn("メール通知に失敗しました", "smtp", opts.mail.smtpAddr, "error", err)
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+// exitEarly は設定の誤りなどでファイルの処理を始める前に異常終了する場合にも、
+// 夜間バッチなどが結果を把握できるよう監査ログの追記とWebhook・メール通知を行ってから終了コード2で終了します。
+// なお、コマンドライン引数の解析自体に失敗した場合は flag パッケージが即座に終了するため通知されません。
+func exitEarly(opts options, info RunInfo, cause error) {
+	info.FinishedAt = time.Now()
+	record := AuditRecord{
+		RunInfo:   info,
+		TargetDir: opts.targetDir,
+		Config:    effectiveConfig(flag.CommandLine),
+		Usage:     collectUsage(info.StartedAt, info.FinishedAt),
+		ExitCode:  2,
+		Error:     cause.Error(),
+	}
+	if opts.auditPath != "" {
+		if err := AppendAudit(opts.auditPath, record); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 監査ログの追記に失敗しました: %v\n", err)
+		}
+	}
+	result := NewRunResult(opts.targetDir, Stats{}, 2, cause)
+	if opts.webhookURL != "" {
+		if err := PostWebhook(opts.webhookURL, result); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: Webhook通知に失敗しました（%s）: %v\n", redactURL(opts.webhookURL), err)
+		}
+	}
+	if opts.mail.to != "" {
+		if err := MailRunResult(opts.mail, result, record); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: メール通知に失敗しました（%s）: %v\n", opts.mail.smtpAddr, err)
+		}
+	}
+	os.Exit(2)
+}
+
+// run は変換処理を実行し、終了コードと異常終了の原因となったエラーを返します。
+func run(opts options, processor *Processor) (int, error) {
+	logger := processor.Logger
+
+	if opts.journal != "" {
+		journal, err := LoadJournal(opts.journal)
+		if err != nil {
+			logger.Error("処理記録を読み込めませんでした", "path", opts.journal, "error", err)
+			return 2, err
+		}
+		processor.Journal = journal
+		// 途中でエラーになった場合も、それまでに処理したファイルは記録に残す
+		defer func() {
+			if err := journal.Save(); err != nil {
+				logger.Warn("処理記録を保存できませんでした", "path", opts.journal, "error", err)
+			}
+		}()
+	}
+
+	if opts.expectPath != "" {
+		expected, err := ReadListFile(opts.expectPath)
+		if err != nil {
+			logger.Error("想定ファイル一覧を読み込めませんでした", "path", opts.expectPath, "error", err)
+			return 2, err
+		}
+		processor.ExpectedFiles = expected
+	}
+
+	var anyReplaced bool
+	var err error
+	switch {
+	case opts.singleFile != "":
+		anyReplaced, err = processor.ProcessFiles([]string{opts.singleFile})
+	case len(opts.filePaths) > 0:
+		anyReplaced, err = processor.ProcessFiles(opts.filePaths)
+	case opts.filesPath != "":
+		anyReplaced, err = runFiles(processor, opts.filesPath)
+	case opts.batch:
+		anyReplaced, err = runBatch(processor, opts.targetDir)
+	default:
+		anyReplaced, err = processor.ProcessDirectory(opts.targetDir)
+	}
 	if err != nil {
 		logger.Error("例外エラーにより異常終了します", "error", err)
-		os.Exit(2)
+		return 2, err
+	}
+
+	stats := processor.Stats
+	summary := []any{
+		"files_processed", stats.FilesProcessed,
+		"files_replaced", stats.FilesReplaced,
+		"files_skipped", stats.FilesSkipped,
+		"files_failed", stats.FilesFailed,
+		"files_deferred", stats.FilesDeferred,
+		"rows_read", stats.RowsRead,
+		"rows_replaced", stats.RowsReplaced,
 	}
 
 	if anyReplaced {
-		logger.Info("処理が完了しました（置換あり）")
-		os.Exit(1)
+		logger.Info("処理が完了しました（置換あり）", summary...)
+		return 1, nil
+	}
+
+	// 保留したファイルは内容を確認していないため、「置換対象なし」と区別できるよう別の終了コードとする
+	if stats.FilesDeferred > 0 {
+		logger.Warn("書き込み中の可能性があるファイルを保留しました。保留したファイルは未確認です", summary...)
+		return 3, nil
 	}
 
-	logger.Info("置換対象のデータはありませんでした")
-	os.Exit(0)
+	logger.Info("置換対象のデータはありませんでした", summary...)
+	return 0, nil
+}
+
+// runFiles は一覧ファイルに記載されたファイルのみを処理します。
+func runFiles(processor *Processor, manifestPath string) (bool, error) {
+	paths, err := ReadFileManifest(manifestPath)
+	if err != nil {
+		return false, err
+	}
+	processor.Logger.Debug("一覧ファイルを読み込みました", "path", manifestPath, "count", len(paths))
+	return processor.ProcessFiles(paths)
+}
+
+// runBatch は rootDir 直下の各サブディレクトリを処理して一覧表を標準出力に書き出します。
+// いずれかのディレクトリでエラーが発生した場合は、それらをまとめたエラーを返します。
+func runBatch(processor *Processor, rootDir string) (bool, error) {
+	results, err := processor.ProcessBatch(rootDir)
+	if err != nil {
+		return false, err
+	}
+
+	if err := WriteBatchTable(os.Stdout, results, processor.Stats, processor.ShowPath); err != nil {
+		return false, fmt.Errorf("一覧表の出力エラー: %w", err)
+	}
+
+	anyReplaced := false
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", formatPath(processor.ShowPath, r.Dir), r.Err))
+		}
+		if r.Replaced {
+			anyReplaced = true
+		}
+	}
+	return anyReplaced, errors.Join(errs...)
 }
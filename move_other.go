@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package main
+
+// isCrossDevice は別ファイルシステムへの移動を判別できないOS向けの実装で、常に false を返します。
+func isCrossDevice(err error) bool {
+	return false
+}
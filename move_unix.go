@@ -0,0 +1,13 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice は名前の変更が別ファイルシステムへの移動であるために失敗したかを判定します。
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
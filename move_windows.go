@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice は別ドライブへの移動時に MoveFileEx が返す ERROR_NOT_SAME_DEVICE です。
+const errorNotSameDevice syscall.Errno = 17
+
+// isCrossDevice は名前の変更が別ドライブへの移動であるために失敗したかを判定します。
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookTimeout はWebhook送信1回あたりのタイムアウトです。
+const webhookTimeout = 10 * time.Second
+
+// RunResult は処理終了時にWebhookへ送信する実行結果です。
+// Text はSlack互換のWebhookでそのまま表示されるメッセージです。
+type RunResult struct {
+	Text      string `json:"text"`
+	TargetDir string `json:"target_dir"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	Stats     Stats  `json:"stats"`
+}
+
+// NewRunResult は処理件数、終了コードとエラーから送信用の実行結果を組み立てます。
+func NewRunResult(targetDir string, stats Stats, exitCode int, runErr error) RunResult {
+	result := RunResult{
+		TargetDir: targetDir,
+		ExitCode:  exitCode,
+		Stats:     stats,
+	}
+
+	switch {
+	case runErr != nil:
+		result.Error = runErr.Error()
+		result.Text = fmt.Sprintf("[ObuDAte] 異常終了しました: %s (%s)", targetDir, runErr)
+	case exitCode == 1:
+		result.Text = fmt.Sprintf("[ObuDAte] 処理が完了しました（置換あり）: %s", targetDir)
+	case exitCode == 3:
+		result.Text = fmt.Sprintf("[ObuDAte] 書き込み中の可能性があるファイルを%d件保留しました（未確認）: %s", stats.FilesDeferred, targetDir)
+	default:
+		result.Text = fmt.Sprintf("[ObuDAte] 置換対象のデータはありませんでした: %s", targetDir)
+	}
+	return result
+}
+
+// PostWebhook は実行結果をJSONとして指定URLへPOSTします。
+// 返すエラーにはURLのパス・クエリを含めません。
+func PostWebhook(rawURL string, result RunResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("JSON変換エラー: %w", err)
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(rawURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// *url.Error のメッセージには認証情報を含むURL全体が含まれるため、伏せ字にしたURLで組み立て直す
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("Webhook送信エラー: %s %s: %w", urlErr.Op, redactURL(rawURL), urlErr.Err)
+		}
+		return fmt.Errorf("Webhook送信エラー: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook応答エラー: %s", resp.Status)
+	}
+	return nil
+}
+
+// redactURL はログ出力用に、認証情報を含み得るパス・クエリ・ユーザー情報を除いた
+// スキームとホストのみのURLを返します（例: https://hooks.slack.com/***）。
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// envPrefix は設定値を環境変数から与える際の変数名の接頭辞です。
+const envPrefix = "OBUDATE_"
+
+// envDirName は処理対象ディレクトリを与える環境変数名です。
+const envDirName = envPrefix + "DIR"
+
+// envName はフラグ名に対応する環境変数名を返します（例: check-seq → OBUDATE_CHECK_SEQ）。
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnvDefaults は各フラグに対応する環境変数が設定されていれば、その値を既定値として適用します。
+// Parse の前に呼び出すことで、コマンドラインで明示したフラグが環境変数より優先されます。
+func applyEnvDefaults(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		value, ok := lookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("環境変数 %s の値が不正です: %w", name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// secretFlags はログや監査ログに値を出力しないフラグです。
+// SlackなどのWebhook URLはそれ自体が投稿用の認証情報を含みます。
+var secretFlags = map[string]bool{
+	"webhook":       true,
+	"smtp-password": true,
+}
+
+// effectiveConfig は全フラグの最終的な値（既定値・環境変数・コマンドライン指定を反映済み）を返します。
+// secretFlags に含まれるフラグの値は伏せ字にします。
+func effectiveConfig(fs *flag.FlagSet) map[string]string {
+	config := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "***"
+		}
+		config[f.Name] = value
+	})
+	return config
+}
+
+// parseTimeBound は期間指定の値を解釈します。RFC3339形式の日時、日付（2006-01-02、ローカル時刻の0時）、
+// または now から遡る時間（例: 24h）を受け付けます。
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("日時として解釈できません: %q（RFC3339、2006-01-02 または 24h などの時間で指定してください）", value)
+}
+
+// timeBoundValue は parseTimeBound で解釈した日時を保持するフラグ値です。
+type timeBoundValue struct {
+	t *time.Time
+}
+
+func (v timeBoundValue) String() string {
+	if v.t == nil || v.t.IsZero() {
+		return ""
+	}
+	return v.t.Format(time.RFC3339)
+}
+
+func (v timeBoundValue) Set(value string) error {
+	t, err := parseTimeBound(value, time.Now())
+	if err != nil {
+		return err
+	}
+	*v.t = t
+	return nil
+}
+
+// classifyArgs は位置引数を処理対象ディレクトリとファイルの一覧に振り分けます。
+// 引数が1つだけで、ディレクトリであるか存在しない（ワイルドカードを含まない）場合は従来どおり
+// ディレクトリとして扱い、それ以外はすべてファイルとして扱います。シェルが展開しない環境
+// （Windowsのコマンドプロンプトなど）向けに、存在しないパスのうちワイルドカードを含むものは
+// 一致するファイルに展開します。
+func classifyArgs(args []string) (dir string, files []string) {
+	if len(args) == 1 {
+		info, err := os.Stat(args[0])
+		if err == nil && info.IsDir() || err != nil && !strings.ContainsAny(args[0], "*?[") {
+			return args[0], nil
+		}
+	}
+	for _, arg := range args {
+		files = append(files, expandGlob(arg)...)
+	}
+	return "", files
+}
+
+// expandGlob はワイルドカードを含む存在しないパスを一致するCSVファイルのパスに展開します。
+// ディレクトリを処理する場合と同様に、出力済みの .cs_ などCSV以外のファイルは除きます。
+// 展開できない場合はパスをそのまま返し、存在しない旨の報告は validateOptions に委ねます。
+func expandGlob(path string) []string {
+	if !strings.ContainsAny(path, "*?[") {
+		return []string{path}
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return []string{path}
+	}
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return []string{path}
+	}
+	matches = slices.DeleteFunc(matches, func(m string) bool { return !isCSV(m) })
+	if len(matches) == 0 {
+		return []string{path}
+	}
+	return matches
+}
+
+// validateOptions はファイルの処理を始める前に設定値全体の整合性を検証し、
+// 見つかった問題を対処方法とあわせてまとめて返します。
+func validateOptions(opts options) error {
+	var errs []error
+	addf := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if opts.filesPath != "" && opts.batch {
+		addf("-files と -batch は同時に指定できません。どちらか一方を指定してください")
+	}
+	if opts.singleFile != "" && (opts.filesPath != "" || opts.batch) {
+		addf("-file は -files・-batch と同時に指定できません。どれか1つを指定してください")
+	}
+	if len(opts.filePaths) > 0 && (opts.singleFile != "" || opts.filesPath != "" || opts.batch) {
+		addf("ファイルの位置引数は -file・-files・-batch と同時に指定できません。どれか1つの方法で処理対象を指定してください")
+	}
+	// ファイルを直接指定する場合はディレクトリ一覧を取得しないため、一覧に対する検証・取得方法の指定は効かない
+	fileMode := opts.singleFile != "" || opts.filesPath != "" || len(opts.filePaths) > 0
+	dirChecks := opts.expectPath != "" || opts.checkSeq
+	if fileMode && dirChecks {
+		addf("-expect・-check-seq はディレクトリを処理する場合のみ有効です。-file・-files・ファイルの位置引数とは併用できません")
+	}
+	switch {
+	case opts.streamList && fileMode:
+		addf("-stream-list はディレクトリを処理する場合のみ有効です。-file・-files・ファイルの位置引数とは併用できません")
+	case opts.streamList && dirChecks:
+		addf("-stream-list は -expect・-check-seq と併用できません。検証が必要な場合は -stream-list を外してください")
+	}
+	if opts.streamList && (opts.doneDir != "" || opts.errorDir != "") {
+		addf("-stream-list は -done-dir・-error-dir と併用できません。一覧の取得中にファイルを移動すると処理漏れが起こり得るため、どちらかを外してください")
+	}
+	if opts.mail.to != "" && (opts.mail.smtpAddr == "" || opts.mail.from == "") {
+		addf("-mail-to を指定する場合は -smtp と -mail-from も指定してください")
+	}
+	if opts.mail.to == "" && (opts.mail.smtpAddr != "" || opts.mail.from != "" || opts.mail.user != "") {
+		addf("-smtp・-mail-from・-smtp-user は -mail-to を指定した場合のみ有効です。送信先を -mail-to で指定してください")
+	}
+	if opts.mail.smtpAddr != "" {
+		if _, _, err := net.SplitHostPort(opts.mail.smtpAddr); err != nil {
+			addf("-smtp に指定したアドレス %s が不正です。host:port の形式で指定してください（例: smtp.example.com:587）", opts.mail.smtpAddr)
+		}
+	}
+	if opts.retries < 0 {
+		addf("-retry に負の値 %d が指定されています。0 以上を指定してください", opts.retries)
+	}
+	if opts.retryDelay < 0 {
+		addf("-retry-delay に負の値 %s が指定されています。0 以上を指定してください", opts.retryDelay)
+	}
+	if opts.minAge < 0 {
+		addf("-min-age に負の値 %s が指定されています。0 以上を指定してください", opts.minAge)
+	}
+	if opts.maxLine <= 0 {
+		addf("-max-line-size には正の値を指定してください（既定値 %d）", DefaultMaxLineSize)
+	}
+	if !opts.since.IsZero() && !opts.until.IsZero() && opts.since.After(opts.until) {
+		addf("-since（%s）が -until（%s）より後になっています。期間の指定を見直してください",
+			opts.since.Format(time.RFC3339), opts.until.Format(time.RFC3339))
+	}
+
+	switch {
+	case opts.singleFile != "":
+		if err := checkCSVFile("-file に指定したファイル", opts.singleFile); err != nil {
+			errs = append(errs, err)
+		}
+	case opts.filesPath != "":
+		if err := checkReadableFile("-files に指定したファイル", opts.filesPath); err != nil {
+			errs = append(errs, err)
+		}
+	case len(opts.filePaths) > 0:
+		for _, path := range opts.filePaths {
+			if err := checkCSVFile("位置引数のファイル", path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	default:
+		if err := checkDir("処理対象ディレクトリ", opts.targetDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if opts.expectPath != "" {
+		if err := checkReadableFile("-expect に指定したファイル", opts.expectPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, d := range []struct{ label, path string }{{"-done-dir", opts.doneDir}, {"-error-dir", opts.errorDir}} {
+		if d.path == "" {
+			continue
+		}
+		if err := checkDir(d.label, d.path); err != nil {
+			errs = append(errs, err)
+		}
+		switch {
+		case opts.targetDir == "":
+		case samePath(d.path, opts.targetDir):
+			addf("%s に処理対象ディレクトリと同じ %s が指定されています。別のディレクトリを指定してください", d.label, d.path)
+		case opts.batch && isWithin(d.path, opts.targetDir):
+			addf("%s %s が -batch の処理対象 %s の中にあります。次回の実行でサブディレクトリとして処理されるため、外側のディレクトリを指定してください", d.label, d.path, opts.targetDir)
+		}
+	}
+	if opts.doneDir != "" && opts.errorDir != "" && samePath(opts.doneDir, opts.errorDir) {
+		addf("-done-dir と -error-dir に同じ %s が指定されています。成功・失敗を区別できるよう別のディレクトリを指定してください", opts.doneDir)
+	}
+	outputs := []struct{ label, path string }{
+		{"-incremental", opts.journal},
+		{"-audit", opts.auditPath},
+		{"-stats-json", opts.statsJSON},
+		{"-cpuprofile", opts.profile.cpuProfile},
+		{"-memprofile", opts.profile.memProfile},
+	}
+	for _, f := range outputs {
+		if f.path == "" || f.path == "-" {
+			continue
+		}
+		if err := checkDir(f.label+" の出力先ディレクトリ", filepath.Dir(f.path)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkDir は path が読み込み可能なディレクトリであるかを確認します。
+func checkDir(label, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%s %s が存在しません。パスを確認するか、事前に作成してください", label, path)
+		}
+		return fmt.Errorf("%s %s を開けません。アクセス権を確認してください: %w", label, path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s %s の情報を取得できません: %w", label, path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s %s はディレクトリではありません。ディレクトリのパスを指定してください", label, path)
+	}
+	return nil
+}
+
+// checkReadableFile は path が読み込み可能なファイルであるかを確認します。
+func checkReadableFile(label, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%s %s が存在しません。パスを確認してください", label, path)
+		}
+		return fmt.Errorf("%s %s を開けません。アクセス権を確認してください: %w", label, path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s %s の情報を取得できません: %w", label, path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s %s はディレクトリです。ファイルのパスを指定してください", label, path)
+	}
+	return nil
+}
+
+// checkCSVFile は path が読み込み可能なCSVファイルであるかを確認します。
+// 出力ファイル（.cs_）などを入力として指定すると、変換結果で入力自体を上書きしてしまうため拒否します。
+func checkCSVFile(label, path string) error {
+	if err := checkReadableFile(label, path); err != nil {
+		return err
+	}
+	if !isCSV(path) {
+		return fmt.Errorf("%s %s はCSVファイル（.csv）ではありません。変換前のCSVファイルを指定してください", label, path)
+	}
+	return nil
+}
+
+// isWithin は path が dir の配下（dir 自体を除く）にあるかを判定します。
+func isWithin(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// samePath は2つのパスが同じ場所を指すかを判定します。
+func samePath(a, b string) bool {
+	if ia, err := os.Stat(a); err == nil {
+		if ib, err := os.Stat(b); err == nil {
+			return os.SameFile(ia, ib)
+		}
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
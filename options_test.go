@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnvDefaults(t *testing.T) {
+	newFlagSet := func(opts *options) *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		fs.BoolVar(&opts.verbose, "verbose", false, "")
+		fs.StringVar(&opts.expectPath, "expect", "", "")
+		fs.BoolVar(&opts.checkSeq, "check-seq", false, "")
+		return fs
+	}
+	env := map[string]string{
+		"OBUDATE_VERBOSE":   "true",
+		"OBUDATE_EXPECT":    "env.txt",
+		"OBUDATE_CHECK_SEQ": "1",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	t.Run("環境変数が既定値として使われる", func(t *testing.T) {
+		var opts options
+		fs := newFlagSet(&opts)
+		if err := applyEnvDefaults(fs, lookup); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !opts.verbose || opts.expectPath != "env.txt" || !opts.checkSeq {
+			t.Errorf("opts = %+v, 環境変数の値が反映されていません", opts)
+		}
+	})
+
+	t.Run("フラグが環境変数より優先される", func(t *testing.T) {
+		var opts options
+		fs := newFlagSet(&opts)
+		if err := applyEnvDefaults(fs, lookup); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if err := fs.Parse([]string{"-expect", "flag.txt", "-check-seq=false"}); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if opts.expectPath != "flag.txt" || opts.checkSeq {
+			t.Errorf("opts = %+v, フラグの値が優先されていません", opts)
+		}
+	})
+
+	t.Run("不正な値はエラー", func(t *testing.T) {
+		var opts options
+		fs := newFlagSet(&opts)
+		bad := func(key string) (string, bool) {
+			if key == "OBUDATE_CHECK_SEQ" {
+				return "maybe", true
+			}
+			return "", false
+		}
+		if err := applyEnvDefaults(fs, bad); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+	})
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	var opts options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.BoolVar(&opts.checkSeq, "check-seq", false, "")
+	fs.StringVar(&opts.expectPath, "expect", "", "")
+	fs.DurationVar(&opts.minAge, "min-age", 0, "")
+	fs.StringVar(&opts.webhookURL, "webhook", "", "")
+	fs.StringVar(&opts.mail.password, "smtp-password", "", "")
+	if err := fs.Parse([]string{"-check-seq", "-min-age", "2m", "-webhook", "https://hooks.example.com/T000/B000/secret", "-smtp-password", "secret"}); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	got := effectiveConfig(fs)
+	want := map[string]string{"check-seq": "true", "expect": "", "min-age": "2m0s", "webhook": "***", "smtp-password": "***"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %v, want %v", got, want)
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2024, 6, 2, 3, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC3339", "2024-06-01T00:00:00+09:00", time.Date(2024, 5, 31, 15, 0, 0, 0, time.UTC), false},
+		{"日付のみ", "2024-06-01", time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), false},
+		{"相対時間", "24h", time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC), false},
+		{"不正な値", "yesterday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeBound(tt.value, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "list.txt")
+	if err := os.WriteFile(file, []byte("INS_01.csv\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	csvFile := filepath.Join(dir, "INS_01.csv")
+	if err := os.WriteFile(csvFile, nil, 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	doneDir := filepath.Join(dir, "done")
+	if err := os.Mkdir(doneDir, 0755); err != nil {
+		t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+	valid := options{targetDir: dir, maxLine: DefaultMaxLineSize}
+
+	tests := []struct {
+		name      string
+		modify    func(*options)
+		wantInErr []string
+	}{
+		{"正常", func(o *options) {}, nil},
+		{"すべての出力先が存在する", func(o *options) {
+			o.expectPath = file
+			o.doneDir = doneDir
+			o.journal = filepath.Join(dir, "journal.json")
+			o.statsJSON = "-"
+		}, nil},
+		{"-files 指定時は処理対象ディレクトリ不要", func(o *options) { o.targetDir = ""; o.filesPath = file }, nil},
+		{"-file 指定時は処理対象ディレクトリ不要", func(o *options) { o.targetDir = ""; o.singleFile = csvFile }, nil},
+		{"-file にCSV以外を指定", func(o *options) { o.targetDir = ""; o.singleFile = file }, []string{"-file に指定したファイル " + file + " はCSVファイル（.csv）ではありません"}},
+		{"-file と -files の併用", func(o *options) { o.singleFile = csvFile; o.filesPath = file }, []string{"-file は -files・-batch と同時に指定できません"}},
+		{"-file が存在しない", func(o *options) { o.singleFile = missing }, []string{"-file に指定したファイル " + missing + " が存在しません"}},
+		{"位置引数のファイル指定時は処理対象ディレクトリ不要", func(o *options) { o.targetDir = ""; o.filePaths = []string{csvFile} }, nil},
+		{"位置引数に出力ファイルを指定", func(o *options) { o.targetDir = ""; o.filePaths = []string{file} }, []string{"位置引数のファイル " + file + " はCSVファイル（.csv）ではありません"}},
+		{"位置引数のファイルと -files の併用", func(o *options) { o.filePaths = []string{csvFile}; o.filesPath = file }, []string{"ファイルの位置引数は -file・-files・-batch と同時に指定できません"}},
+		{"位置引数のファイルが存在しない", func(o *options) { o.targetDir = ""; o.filePaths = []string{csvFile, missing} }, []string{"位置引数のファイル " + missing + " が存在しません"}},
+		{"-files と -batch の併用", func(o *options) { o.filesPath = file; o.batch = true }, []string{"-files と -batch は同時に指定できません"}},
+		{"-stream-list と -done-dir の併用", func(o *options) { o.streamList = true; o.doneDir = doneDir }, []string{"-stream-list は -done-dir・-error-dir と併用できません"}},
+		{"-file と -check-seq の併用", func(o *options) { o.singleFile = csvFile; o.checkSeq = true }, []string{"-expect・-check-seq はディレクトリを処理する場合のみ有効です"}},
+		{"-files と -expect の併用", func(o *options) { o.filesPath = file; o.expectPath = file }, []string{"-expect・-check-seq はディレクトリを処理する場合のみ有効です"}},
+		{"位置引数のファイルと -stream-list の併用", func(o *options) { o.filePaths = []string{csvFile}; o.streamList = true }, []string{"-stream-list はディレクトリを処理する場合のみ有効です"}},
+		{"-batch で -done-dir が処理対象の中にある", func(o *options) { o.batch = true; o.doneDir = doneDir }, []string{"-done-dir " + doneDir + " が -batch の処理対象 " + dir + " の中にあります"}},
+		{"-batch でなければ処理対象の中の -done-dir は許可する", func(o *options) { o.doneDir = doneDir }, nil},
+		{"プロファイルの出力先が存在しない", func(o *options) { o.profile.memProfile = filepath.Join(missing, "mem.pprof") }, []string{"-memprofile の出力先ディレクトリ " + missing + " が存在しません"}},
+		{"-stream-list と -check-seq の併用", func(o *options) { o.streamList = true; o.checkSeq = true }, []string{"-stream-list は -expect・-check-seq と併用できません"}},
+		{"処理対象ディレクトリが存在しない", func(o *options) { o.targetDir = missing }, []string{"処理対象ディレクトリ " + missing + " が存在しません"}},
+		{"処理対象がファイル", func(o *options) { o.targetDir = file }, []string{"ディレクトリではありません"}},
+		{"-expect がディレクトリ", func(o *options) { o.expectPath = dir }, []string{"-expect に指定したファイル " + dir + " はディレクトリです"}},
+		{"メール通知の設定が揃っている", func(o *options) {
+			o.mail = mailOptions{to: "ops@example.com", from: "obudate@example.com", smtpAddr: "smtp.example.com:587"}
+		}, nil},
+		{"-mail-to のみ指定", func(o *options) { o.mail.to = "ops@example.com" }, []string{"-mail-to を指定する場合は -smtp と -mail-from も指定してください"}},
+		{"-mail-to なしで -smtp を指定", func(o *options) { o.mail.smtpAddr = "smtp.example.com:587" }, []string{"-smtp・-mail-from・-smtp-user は -mail-to を指定した場合のみ有効です"}},
+		{"-smtp にポートがない", func(o *options) {
+			o.mail = mailOptions{to: "ops@example.com", from: "obudate@example.com", smtpAddr: "smtp.example.com"}
+		}, []string{"-smtp に指定したアドレス smtp.example.com が不正です"}},
+		{"-done-dir と -error-dir が同じ", func(o *options) { o.doneDir = doneDir; o.errorDir = doneDir + string(filepath.Separator) }, []string{"-done-dir と -error-dir に同じ"}},
+		{"-done-dir が処理対象と同じ", func(o *options) { o.doneDir = dir }, []string{"-done-dir に処理対象ディレクトリと同じ"}},
+		{"期間の前後が逆", func(o *options) {
+			o.since = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+			o.until = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		}, []string{"-since（2024-02-01T00:00:00Z）が -until（2024-01-01T00:00:00Z）より後"}},
+		{"問題をまとめて報告する", func(o *options) {
+			o.retries = -1
+			o.maxLine = 0
+			o.errorDir = missing
+			o.auditPath = filepath.Join(missing, "audit.jsonl")
+		}, []string{
+			"-retry に負の値 -1",
+			"-max-line-size には正の値を指定してください",
+			"-error-dir " + missing + " が存在しません",
+			"-audit の出力先ディレクトリ " + missing + " が存在しません",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			err := validateOptions(opts)
+			if (err != nil) != (tt.wantInErr != nil) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantInErr != nil)
+			}
+			for _, want := range tt.wantInErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("エラーメッセージ %q に %q が含まれていません", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"INS_202406_01.csv", "INS_202406_01.cs_", "INS_202406_02.csv", "INS_202407_01.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	file1 := filepath.Join(dir, "INS_202406_01.csv")
+	file2 := filepath.Join(dir, "INS_202406_02.csv")
+	missing := filepath.Join(dir, "missing")
+	noMatch := filepath.Join(dir, "UPD_*.csv")
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantDir   string
+		wantFiles []string
+	}{
+		{"ディレクトリ1つ", []string{dir}, dir, nil},
+		{"存在しないパス1つはディレクトリとして扱う", []string{missing}, missing, nil},
+		{"ファイル1つ", []string{file1}, "", []string{file1}},
+		{"複数のファイル", []string{file2, file1}, "", []string{file2, file1}},
+		{"ワイルドカードを展開する", []string{filepath.Join(dir, "INS_202406*.csv")}, "", []string{file1, file2}},
+		{"一致しないワイルドカードはそのまま", []string{noMatch}, "", []string{noMatch}},
+		{"ワイルドカードはCSV以外を除く", []string{filepath.Join(dir, "*")}, "", []string{file1, file2, filepath.Join(dir, "INS_202407_01.csv")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotDir, gotFiles := classifyArgs(tt.args)
+			if gotDir != tt.wantDir || !reflect.DeepEqual(gotFiles, tt.wantFiles) {
+				t.Errorf("classifyArgs(%v) = %q, %v, want %q, %v", tt.args, gotDir, gotFiles, tt.wantDir, tt.wantFiles)
+			}
+		})
+	}
+}
@@ -2,38 +2,369 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"iter"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
+// openFile はファイルを読み込み用に開きます。テストで一時的なエラーを再現するために差し替えられます。
+var openFile = func(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// createFile は出力ファイルを作成します。テストで書き込みエラーを再現するために差し替えられます。
+var createFile = func(name string) (io.WriteCloser, error) {
+	return os.Create(name)
+}
+
+// Stats は処理結果の件数を集計します。
+// 処理に失敗したファイルは FilesFailed のみに数え、読み込んだ行数なども加算しません。
+type Stats struct {
+	FilesProcessed int `json:"files_processed"` // 処理を終えたCSVファイル数
+	FilesReplaced  int `json:"files_replaced"`  // 置換が発生し .cs_ を出力したファイル数
+	FilesSkipped   int `json:"files_skipped"`   // CSV以外、対象期間外、前回から変更なしのため処理対象外としたファイル数
+	FilesFailed    int `json:"files_failed"`    // 読み込み、出力、移動のいずれかに失敗したファイル数
+	FilesDeferred  int `json:"files_deferred"`  // 書き込み中の可能性があるため処理を保留したファイル数
+	RowsRead       int `json:"rows_read"`       // 読み込んだ行数
+	RowsReplaced   int `json:"rows_replaced"`   // 置換が発生した行数
+}
+
+// add は他の集計結果を加算します。
+func (s *Stats) add(other Stats) {
+	s.FilesProcessed += other.FilesProcessed
+	s.FilesReplaced += other.FilesReplaced
+	s.FilesSkipped += other.FilesSkipped
+	s.FilesFailed += other.FilesFailed
+	s.FilesDeferred += other.FilesDeferred
+	s.RowsRead += other.RowsRead
+	s.RowsReplaced += other.RowsReplaced
+}
+
+// FileStatus はファイル単位の処理結果の種別です。
+type FileStatus string
+
+const (
+	FileReplaced  FileStatus = "replaced"  // 置換が発生し .cs_ を出力した
+	FileUnchanged FileStatus = "unchanged" // 置換対象がなかった
+	FileDeferred  FileStatus = "deferred"  // 書き込み中の可能性があるため保留した
+	FileFailed    FileStatus = "failed"    // 読み込み、出力、移動のいずれかに失敗した（.cs_ は残さない）
+)
+
+// FileResult は1ファイル分の処理結果です。
+type FileResult struct {
+	Path         string     `json:"path"`
+	Status       FileStatus `json:"status"`
+	ReplaceCount int        `json:"replace_count"`
+	DurationSec  float64    `json:"duration_sec,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// DirResult はバッチ処理における1ディレクトリ分の処理結果です。
+type DirResult struct {
+	Dir      string
+	Replaced bool
+	Stats    Stats
+	Err      error
+}
+
 // Processor は変換処理全体を管理する構造体です。
 type Processor struct {
 	Logger *slog.Logger
+
+	// ExpectedFiles が nil でない場合、ディレクトリ内のCSVファイル名が
+	// この一覧と完全に一致することを処理前に検証します。
+	ExpectedFiles []string
+
+	// CheckSequence が true の場合、ファイル名末尾の連番に欠番や重複がないことを
+	// 処理前に検証します。
+	CheckSequence bool
+
+	// KeepGoing が true の場合、ファイル単位のエラーが発生しても残りのファイルの処理を継続し、
+	// 全ファイルの処理後に失敗件数をエラーとして返します。
+	KeepGoing bool
+
+	// Retries はファイルのオープン・読み込みに失敗した際の再試行回数です。
+	// 再試行の待機時間は RetryDelay から始まり、1回ごとに倍になります。
+	Retries    int
+	RetryDelay time.Duration
+
+	// MinAge が正の場合、最終更新からの経過時間がこれに満たないファイルは
+	// 書き込み中の可能性があるとみなして処理を保留します。
+	MinAge time.Duration
+
+	// Since・Until がゼロ値でない場合、最終更新日時がこの期間外のファイルはスキップします。
+	Since time.Time
+	Until time.Time
+
+	// Journal が nil でない場合、前回から変更のないファイルをスキップし、
+	// 正常に処理したファイルを記録します。
+	Journal *Journal
+
+	// DoneDir が空でない場合、正常に処理を終えた入力ファイルをこのディレクトリへ移動します。
+	DoneDir string
+
+	// ErrorDir が空でない場合、処理に失敗した入力ファイルをこのディレクトリへ移動し、
+	// エラー内容を記載した <ファイル名>.error.txt を並べて作成します。
+	// 変換後に DoneDir への移動などで失敗したファイルも含み、その .cs_ は入力ディレクトリに残しません。
+	ErrorDir string
+
+	// RunID が空でない場合、DoneDir・ErrorDir の下にこの名前のサブディレクトリを作成して移動します。
+	// 毎日同じ名前で届くファイルが過去の実行で移動したファイルと衝突しないようにするためです。
+	RunID string
+
+	// batchRoot は ProcessBatch の処理中のみ設定され、移動先にサブディレクトリの階層を再現するために使用します。
+	batchRoot string
+
+	// StreamListing が true の場合、ディレクトリ一覧を一括で読み込まずに少しずつ取得し、
+	// 一覧の取得と並行して取得済みのファイルから処理を始めます。
+	// ファイル一覧全体を必要とする ExpectedFiles・CheckSequence とは併用できません。
+	// また、一覧の取得中に対象ディレクトリからファイルを移動する DoneDir・ErrorDir とも併用できません。
+	StreamListing bool
+
+	// MaxLineSize は1行あたりの最大バイト数です。0 以下の場合は DefaultMaxLineSize を使用します。
+	// 上限を超える行を含むファイルは処理に失敗します。
+	MaxLineSize int
+
+	// ShowPath はログに出力するファイルパスの表記です。空の場合は指定されたパスをそのまま出力します。
+	ShowPath PathStyle
+
+	// Stats は処理中に集計された件数です。複数回の処理呼び出しにまたがって加算されます。
+	Stats Stats
+
+	// Results は処理対象としたファイルごとの結果です。Stats と同様に加算されます。
+	Results []FileResult
+}
+
+// PathStyle はログに出力するファイルパスの表記方法です。
+type PathStyle string
+
+const (
+	PathRelative PathStyle = "relative" // 作業ディレクトリからの相対パス
+	PathAbsolute PathStyle = "absolute" // 絶対パス
+	PathBase     PathStyle = "base"     // ファイル名のみ
+)
+
+func (s *PathStyle) String() string {
+	return string(*s)
+}
+
+func (s *PathStyle) Set(value string) error {
+	switch style := PathStyle(value); style {
+	case PathRelative, PathAbsolute, PathBase:
+		*s = style
+		return nil
+	}
+	return fmt.Errorf("relative, absolute, base のいずれかを指定してください: %q", value)
+}
+
+// displayPath は ShowPath に従ってログ出力用のファイルパスを返します。
+func (p *Processor) displayPath(path string) string {
+	return formatPath(p.ShowPath, path)
+}
+
+// formatPath は style に従って表示用のパスを返します。
+// パスを解決できない場合は指定されたパスをそのまま返します。
+func formatPath(style PathStyle, path string) string {
+	switch style {
+	case PathBase:
+		return filepath.Base(path)
+	case PathAbsolute, PathRelative:
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return path
+		}
+		if style == PathAbsolute {
+			return abs
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return path
+		}
+		rel, err := filepath.Rel(wd, abs)
+		if err != nil {
+			return path
+		}
+		return rel
+	}
+	return path
+}
+
+// DefaultMaxLineSize は MaxLineSize 未指定時の1行あたりの最大バイト数です。
+// 備考欄などに長い自由記述を含む行を想定し、bufio.Scanner の既定値より大きくしています。
+const DefaultMaxLineSize = 64 << 20
+
+// listPageSize は StreamListing 時に一度に取得するディレクトリエントリ数です。
+var listPageSize = 1000
+
+// isOutput はファイル名が本ツールの出力ファイル（.cs_）であるかを判定します。
+// 出力ファイルは処理対象外ですが、スキップ件数には数えません。
+func isOutput(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".cs_")
+}
+
+// isCSV はファイル名の拡張子がCSV（大文字小文字を区別しない）であるかを判定します。
+func isCSV(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".csv"
 }
 
 // ProcessDirectory は指定ディレクトリ直下のCSVファイルを処理します。
 func (p *Processor) ProcessDirectory(targetDir string) (bool, error) {
+	if p.StreamListing {
+		return p.streamDirectory(targetDir)
+	}
+
 	entries, err := os.ReadDir(targetDir)
 	if err != nil {
 		return false, fmt.Errorf("ディレクトリ読み込みエラー: %w", err)
 	}
 
-	anyFileReplaced := false
-
+	var names []string
 	for _, entry := range entries {
 		// サブディレクトリやCSV以外のファイルはスキップ
-		if entry.IsDir() || strings.ToLower(filepath.Ext(entry.Name())) != ".csv" {
+		if entry.IsDir() {
 			continue
 		}
+		if !isCSV(entry.Name()) {
+			if !isOutput(entry.Name()) {
+				p.Stats.FilesSkipped++
+			}
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	if p.ExpectedFiles != nil {
+		if err := checkExpectedFiles(names, p.ExpectedFiles); err != nil {
+			p.Logger.Error("ファイル構成が想定と一致しません", "dir", p.displayPath(targetDir), "error", err)
+			return false, fmt.Errorf("ファイル構成エラー: %w", err)
+		}
+	}
+
+	if p.CheckSequence {
+		if err := checkSequence(names); err != nil {
+			p.Logger.Error("ファイル名の連番に問題があります", "dir", p.displayPath(targetDir), "error", err)
+			return false, fmt.Errorf("連番検証エラー: %w", err)
+		}
+	}
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(targetDir, name)
+	}
+	return p.ProcessFiles(paths)
+}
 
-		filePath := filepath.Join(targetDir, entry.Name())
-		replaced, err := p.processFile(filePath)
+// streamDirectory はディレクトリ一覧を listPageSize 件ずつ別のゴルーチンで取得しながら、
+// 取得済みのCSVファイルを順に処理します。各ページ内はファイル名順に処理します。
+func (p *Processor) streamDirectory(targetDir string) (bool, error) {
+	if p.ExpectedFiles != nil || p.CheckSequence {
+		return false, errors.New("一覧の逐次取得では想定ファイル・連番の検証を使用できません")
+	}
+
+	dir, err := os.Open(targetDir)
+	if err != nil {
+		return false, fmt.Errorf("ディレクトリ読み込みエラー: %w", err)
+	}
+
+	pageSize := listPageSize
+	paths := make(chan string, pageSize)
+	done := make(chan struct{})
+	// 途中で処理を中断した場合も、一覧取得のゴルーチンの終了を待ってから戻る
+	defer func() {
+		close(done)
+		for range paths {
+		}
+	}()
+
+	// listErr と skipped は paths のクローズ後にのみ参照する
+	var listErr error
+	skipped := 0
+	go func() {
+		defer close(paths)
+		defer dir.Close()
+		for {
+			entries, err := dir.ReadDir(pageSize)
+			slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+				return strings.Compare(a.Name(), b.Name())
+			})
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				// 処理中に作成した .cs_ が一覧に現れるかは実行ごとに異なるため、件数に含めない
+				if !isCSV(entry.Name()) {
+					if !isOutput(entry.Name()) {
+						skipped++
+					}
+					continue
+				}
+				select {
+				case paths <- filepath.Join(targetDir, entry.Name()):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					listErr = fmt.Errorf("ディレクトリ読み込みエラー: %w", err)
+				}
+				return
+			}
+		}
+	}()
+
+	anyFileReplaced, err := p.processAll(func(yield func(string) bool) {
+		for path := range paths {
+			if !yield(path) {
+				return
+			}
+		}
+	})
+	if err != nil && !p.KeepGoing {
+		return false, err
+	}
+
+	p.Stats.FilesSkipped += skipped
+	if listErr != nil {
+		return anyFileReplaced, errors.Join(err, listErr)
+	}
+	return anyFileReplaced, err
+}
+
+// ProcessFiles は指定されたファイルをディレクトリの走査を行わずに順に処理します。
+// 拡張子による絞り込みは行いません。
+func (p *Processor) ProcessFiles(paths []string) (bool, error) {
+	return p.processAll(slices.Values(paths))
+}
+
+// processAll は paths から得たファイルを順に処理します。
+// KeepGoing でなければ最初に失敗したファイルで処理を中断します。
+func (p *Processor) processAll(paths iter.Seq[string]) (bool, error) {
+	anyFileReplaced := false
+	failedCount := 0
+
+	for filePath := range paths {
+		replaced, err := p.processOne(filePath)
 		if err != nil {
-			p.Logger.Error("ファイル処理中にエラーが発生しました", "file", filePath, "error", err)
-			return false, err
+			p.Stats.FilesFailed++
+			p.Results = append(p.Results, FileResult{Path: filePath, Status: FileFailed, Error: err.Error()})
+			p.Logger.Error("ファイル処理中にエラーが発生しました", "file", p.displayPath(filePath), "error", err)
+			if p.ErrorDir != "" {
+				p.moveToErrorDir(filePath, err)
+			}
+			if !p.KeepGoing {
+				return false, err
+			}
+			failedCount++
+			continue
 		}
 
 		if replaced {
@@ -41,58 +372,342 @@ func (p *Processor) ProcessDirectory(targetDir string) (bool, error) {
 		}
 	}
 
+	if failedCount > 0 {
+		return anyFileReplaced, fmt.Errorf("ファイル処理エラー: %d件のファイルで処理に失敗しました", failedCount)
+	}
 	return anyFileReplaced, nil
 }
 
-func (p *Processor) processFile(srcPath string) (bool, error) {
-	srcFile, err := os.Open(srcPath)
+// ProcessBatch は rootDir 直下の各サブディレクトリを独立して処理し、ディレクトリごとの結果を返します。
+// あるディレクトリでエラーが発生しても残りのディレクトリの処理は継続し、エラーは DirResult.Err に記録します。
+// Stats には全ディレクトリの合計が加算されます。
+func (p *Processor) ProcessBatch(rootDir string) ([]DirResult, error) {
+	entries, err := os.ReadDir(rootDir)
 	if err != nil {
-		return false, fmt.Errorf("ファイルオープンエラー: %w", err)
+		return nil, fmt.Errorf("ディレクトリ読み込みエラー: %w", err)
 	}
-	defer srcFile.Close()
 
-	scanner := bufio.NewScanner(srcFile)
-	var lines []string
-	fileReplaced := false
-	replaceCount := 0
+	var results []DirResult
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
 
-	for scanner.Scan() {
-		newLine, replaced := ReplaceTime(scanner.Text())
+		dir := filepath.Join(rootDir, entry.Name())
+		p.batchRoot = rootDir
+		total := p.Stats
+		p.Stats = Stats{}
+
+		p.Logger.Debug("ディレクトリの処理を開始します", "dir", p.displayPath(dir))
+		replaced, err := p.ProcessDirectory(dir)
+		if err != nil {
+			p.Logger.Error("ディレクトリ処理中にエラーが発生しました", "dir", p.displayPath(dir), "error", err)
+		}
+
+		results = append(results, DirResult{
+			Dir:      dir,
+			Replaced: replaced,
+			Stats:    p.Stats,
+			Err:      err,
+		})
+		total.add(p.Stats)
+		p.Stats = total
+	}
+	p.batchRoot = ""
+
+	return results, nil
+}
+
+// processOne はファイルが処理対象の条件を満たしているか確認したうえで変換処理を行います。
+// 条件を満たさず保留したファイルは置換なしとして扱います。
+// 変換後の移動や記録に失敗した場合も失敗として扱い、出力した .cs_ は削除します。
+func (p *Processor) processOne(srcPath string) (bool, error) {
+	startedAt := time.Now()
+	var info fs.FileInfo
+	if p.MinAge > 0 || !p.Since.IsZero() || !p.Until.IsZero() || p.Journal != nil {
+		var err error
+		info, err = os.Stat(srcPath)
+		if err != nil {
+			return false, fmt.Errorf("ファイル情報取得エラー: %w", err)
+		}
+		modTime := info.ModTime()
+		if (!p.Since.IsZero() && modTime.Before(p.Since)) || (!p.Until.IsZero() && modTime.After(p.Until)) {
+			p.Stats.FilesSkipped++
+			p.Logger.Debug("更新日時が対象期間外のためスキップします", "file", p.displayPath(srcPath), "mod_time", modTime)
+			return false, nil
+		}
+		if age := time.Since(modTime); age < p.MinAge {
+			p.Stats.FilesDeferred++
+			p.Results = append(p.Results, FileResult{Path: srcPath, Status: FileDeferred})
+			p.Logger.Info("書き込み中の可能性があるため処理を保留します", "file", p.displayPath(srcPath), "mod_time", modTime, "age", age.Round(time.Second))
+			return false, nil
+		}
+	}
+
+	if p.Journal != nil {
+		unchanged, err := p.Journal.Unchanged(srcPath, info)
+		if err != nil {
+			return false, fmt.Errorf("処理記録との照合エラー: %w", err)
+		}
+		if unchanged {
+			p.Stats.FilesSkipped++
+			p.Logger.Debug("前回の処理から変更がないためスキップします", "file", p.displayPath(srcPath))
+			return false, nil
+		}
+	}
+
+	converted, err := p.processFile(srcPath)
+	if err != nil {
+		return false, err
+	}
+	replaceCount := converted.replaceCount
+	replaced := replaceCount > 0
+
+	if err := p.finishFile(srcPath, info, replaced); err != nil {
+		// 失敗として報告したファイルの出力が後続の取り込みに渡らないよう、出力済みの .cs_ を削除する
 		if replaced {
-			fileReplaced = true
-			replaceCount++
+			if rmErr := os.Remove(outputPath(srcPath)); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+				p.Logger.Warn("失敗したファイルの出力を削除できませんでした", "file", p.displayPath(outputPath(srcPath)), "error", rmErr)
+			}
 		}
-		lines = append(lines, newLine)
+		return false, err
 	}
-	if err := scanner.Err(); err != nil {
-		return false, fmt.Errorf("ファイル読み込みエラー: %w", err)
+
+	// 移動や記録に失敗したファイルが処理済みと失敗の両方に数えられないよう、全工程の成功後に集計する
+	p.Stats.FilesProcessed++
+	p.Stats.RowsRead += converted.rows
+	status := FileUnchanged
+	if replaced {
+		p.Stats.FilesReplaced++
+		p.Stats.RowsReplaced += replaceCount
+		status = FileReplaced
 	}
+	p.Results = append(p.Results, FileResult{
+		Path:         srcPath,
+		Status:       status,
+		ReplaceCount: replaceCount,
+		DurationSec:  time.Since(startedAt).Seconds(),
+	})
+	return replaced, nil
+}
 
-	// 置換対象がなければ新しいファイルは作成しない
-	if !fileReplaced {
-		p.Logger.Debug("置換対象なし、スキップします", "file", srcPath)
-		return false, nil
+// finishFile は変換を終えたファイルを DoneDir へ移動し、処理記録に追加します。
+// 記録内容は移動前のファイルから作成し、移動まで成功した場合のみ処理済みとして記録します。
+func (p *Processor) finishFile(srcPath string, info fs.FileInfo, replaced bool) error {
+	var entry JournalEntry
+	if p.Journal != nil {
+		var output string
+		if replaced {
+			output = outputPath(srcPath)
+		}
+		var err error
+		if entry, err = NewJournalEntry(srcPath, info, output); err != nil {
+			return fmt.Errorf("処理記録の更新エラー: %w", err)
+		}
+	}
+
+	if p.DoneDir != "" {
+		destPath, err := p.archive(p.DoneDir, srcPath)
+		if err != nil {
+			return fmt.Errorf("処理済みファイルの移動エラー: %w", err)
+		}
+		p.Logger.Debug("処理済みファイルを移動しました", "file", p.displayPath(srcPath), "dest", p.displayPath(destPath))
+	}
+
+	if p.Journal != nil {
+		if err := p.Journal.Record(srcPath, entry); err != nil {
+			return fmt.Errorf("処理記録の更新エラー: %w", err)
+		}
 	}
+	return nil
+}
 
+// outputPath は入力ファイルに対応する出力ファイル（拡張子を .cs_ に置き換えたパス）を返します。
+func outputPath(srcPath string) string {
 	ext := filepath.Ext(srcPath)
-	destPath := srcPath[:len(srcPath)-len(ext)] + ".cs_"
+	return srcPath[:len(srcPath)-len(ext)] + ".cs_"
+}
+
+// processFile はファイルを変換して .cs_ を出力し、読み込んだ行数と置換した行数を返します。
+// 返す変換結果には出力済みの内容（data）を含めません。
+func (p *Processor) processFile(srcPath string) (convertedFile, error) {
+	destPath := outputPath(srcPath)
+	// .cs_ 自体を入力にすると変換結果で入力を上書きしてしまう（大文字小文字を区別しないファイルシステムも考慮）
+	if strings.EqualFold(destPath, srcPath) {
+		return convertedFile{}, fmt.Errorf("出力ファイルが入力ファイルと同じパスになるため処理できません: %s", srcPath)
+	}
 
-	destFile, err := os.Create(destPath)
+	converted, err := p.readFile(srcPath)
 	if err != nil {
-		return false, fmt.Errorf("出力ファイル作成エラー: %w", err)
+		return convertedFile{}, err
 	}
-	defer destFile.Close()
+	data := converted.data
+	converted.data = nil
 
-	writer := bufio.NewWriter(destFile)
-	for _, line := range lines {
-		if _, err := writer.WriteString(line + "\r\n"); err != nil {
-			return false, fmt.Errorf("書き込みエラー: %w", err)
+	// 置換対象がなければ新しいファイルは作成しない
+	if converted.replaceCount == 0 {
+		p.Logger.Debug("置換対象なし、スキップします", "file", p.displayPath(srcPath))
+		return converted, nil
+	}
+
+	destFile, err := createFile(destPath)
+	if err != nil {
+		return convertedFile{}, fmt.Errorf("出力ファイル作成エラー: %w", err)
+	}
+	// 書き込みやクローズ（ネットワークドライブでは遅延した書き込みエラーがここで返る）に失敗した場合は、
+	// 途中までの出力ファイルを残さない
+	if _, err := destFile.Write(data); err != nil {
+		destFile.Close()
+		os.Remove(destPath)
+		return convertedFile{}, fmt.Errorf("書き込みエラー: %w", err)
+	}
+	if err := destFile.Close(); err != nil {
+		os.Remove(destPath)
+		return convertedFile{}, fmt.Errorf("出力ファイルのクローズエラー: %w", err)
+	}
+
+	p.Logger.Info("ファイルを変換・出力しました", "source", p.displayPath(srcPath), "output", p.displayPath(destPath), "replace_count", converted.replaceCount)
+	return converted, nil
+}
+
+// convertedFile は1ファイル分の変換結果です。
+type convertedFile struct {
+	data         []byte // 改行を CRLF に揃えた変換後の内容
+	rows         int    // 読み込んだ行数
+	replaceCount int    // 置換した行数
+}
+
+// readFile はファイルを読み込みながら各行を置換し、変換結果を返します。
+// 読み込みに失敗した場合は Retries 回まで、RetryDelay から倍々に待機時間を延ばして再試行します。
+// ファイルが存在しない場合や行長の上限を超えた場合は再試行しても解消しないため、即座に失敗とします。
+func (p *Processor) readFile(srcPath string) (convertedFile, error) {
+	delay := p.RetryDelay
+	for attempt := 1; ; attempt++ {
+		converted, err := p.scanFile(srcPath)
+		if err == nil || attempt > p.Retries || errors.Is(err, fs.ErrNotExist) || errors.Is(err, bufio.ErrTooLong) {
+			return converted, err
+		}
+
+		p.Logger.Warn("ファイル読み込みに失敗したため再試行します", "file", p.displayPath(srcPath), "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// scanFile はファイルを1行ずつ読み込んで変換します。
+// 置換対象を含み得ない行はバイト列のまま出力バッファへ追記し、
+// 文字列への変換と正規表現の評価は置換候補のある行に限って行います。
+func (p *Processor) scanFile(srcPath string) (convertedFile, error) {
+	srcFile, err := openFile(srcPath)
+	if err != nil {
+		return convertedFile{}, fmt.Errorf("ファイルオープンエラー: %w", err)
+	}
+	defer srcFile.Close()
+
+	maxLineSize := p.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	scanner := bufio.NewScanner(srcFile)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxLineSize)), maxLineSize)
+
+	var converted convertedFile
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		converted.rows++
+		if hasOvertimeCandidate(line) {
+			newLine, replaced := ReplaceTime(string(line))
+			if replaced {
+				converted.replaceCount++
+			}
+			converted.data = append(converted.data, newLine...)
+		} else {
+			converted.data = append(converted.data, line...)
+		}
+		converted.data = append(converted.data, "\r\n"...)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return convertedFile{}, fmt.Errorf("ファイル読み込みエラー: %d行目が上限 %d バイトを超えています: %w", converted.rows+1, maxLineSize, err)
+		}
+		return convertedFile{}, fmt.Errorf("ファイル読み込みエラー: %w", err)
+	}
+	return converted, nil
+}
+
+// moveToErrorDir は処理に失敗したファイルを ErrorDir へ移動し、エラー内容のテキストを作成します。
+// 移動自体の失敗は元のエラーを優先するため、ログに記録するのみとします。
+func (p *Processor) moveToErrorDir(srcPath string, cause error) {
+	destPath, err := p.archive(p.ErrorDir, srcPath)
+	if err != nil {
+		p.Logger.Warn("失敗したファイルを移動できませんでした", "file", p.displayPath(srcPath), "dest", p.displayPath(destPath), "error", err)
+		return
+	}
+
+	notePath := destPath + ".error.txt"
+	if err := os.WriteFile(notePath, []byte(cause.Error()+"\r\n"), 0644); err != nil {
+		p.Logger.Warn("エラー内容を書き込めませんでした", "file", p.displayPath(notePath), "error", err)
+	}
+	p.Logger.Info("失敗したファイルを移動しました", "file", p.displayPath(srcPath), "dest", p.displayPath(destPath))
+}
+
+// archive は srcPath を dir の下の <RunID>/<バッチのサブディレクトリ>/<ファイル名> へ移動し、移動先のパスを返します。
+// 異なるサブディレクトリにある同名のファイルが同じ移動先にならないよう、バッチ処理では
+// 処理対象ディレクトリからの相対的な階層を移動先にも設けます。
+func (p *Processor) archive(dir, srcPath string) (string, error) {
+	sub := ""
+	if p.batchRoot != "" {
+		if rel, err := filepath.Rel(p.batchRoot, filepath.Dir(srcPath)); err == nil {
+			sub = rel
 		}
 	}
-	if err := writer.Flush(); err != nil {
-		return false, fmt.Errorf("フラッシュエラー: %w", err)
+	destPath := filepath.Join(dir, p.RunID, sub, filepath.Base(srcPath))
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return destPath, fmt.Errorf("移動先ディレクトリの作成エラー: %w", err)
+	}
+	return destPath, moveFile(srcPath, destPath)
+}
+
+// moveFile はファイルを移動します。移動先に同名のファイルが既にある場合は上書きせずにエラーとします。
+// 別ボリュームへの移動で名前の変更ができない場合に限り、コピーしてから元のファイルを削除します。
+func moveFile(srcPath, destPath string) error {
+	if _, err := os.Lstat(destPath); err == nil {
+		return fmt.Errorf("移動先に同名のファイルが既に存在します: %s", destPath)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("移動先の確認エラー: %w", err)
+	}
+
+	renameErr := os.Rename(srcPath, destPath)
+	if renameErr == nil || !isCrossDevice(renameErr) {
+		return renameErr
+	}
+
+	if err := copyFile(srcPath, destPath); err != nil {
+		return errors.Join(renameErr, err)
+	}
+	return os.Remove(srcPath)
+}
+
+// copyFile は srcPath の内容を新規ファイル destPath にコピーします。
+// destPath が既に存在する場合はエラーとし、コピーに失敗した場合は作成途中のファイルを削除します。
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
 	}
+	defer src.Close()
 
-	p.Logger.Info("ファイルを変換・出力しました", "source", srcPath, "output", destPath, "replace_count", replaceCount)
-	return true, nil
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dest, src)
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destPath)
+		return err
+	}
+	return nil
 }
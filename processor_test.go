@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestProcessDirectory(t *testing.T) {
@@ -54,3 +63,1140 @@ func TestProcessDirectory(t *testing.T) {
 		}
 	})
 }
+
+func TestProcessDirectoryExpectedFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("想定ファイルが不足している場合は変換せずにエラー", func(t *testing.T) {
+		tempDir := t.TempDir()
+		content := "\"2024-02-28\",\"24:30\"\r\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "INS_01.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		processor := &Processor{Logger: logger, ExpectedFiles: []string{"INS_01.csv", "UPD_03.csv"}}
+		if _, err := processor.ProcessDirectory(tempDir); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "INS_01.cs_")); !os.IsNotExist(err) {
+			t.Errorf("検証エラー時に出力ファイルが作成されています")
+		}
+	})
+
+	t.Run("空の想定ファイル一覧ではファイルがあればエラー", func(t *testing.T) {
+		tempDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tempDir, "INS_01.csv"), []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		listPath := filepath.Join(t.TempDir(), "expect.txt")
+		if err := os.WriteFile(listPath, []byte("# 本日は受信なし\r\n"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		expected, err := ReadListFile(listPath)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+
+		processor := &Processor{Logger: logger, ExpectedFiles: expected}
+		_, err = processor.ProcessDirectory(tempDir)
+		if err == nil || !strings.Contains(err.Error(), "想定外のファイルが存在します: INS_01.csv") {
+			t.Errorf("err = %v, 想定外のファイルとしてエラーになるべきです", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "INS_01.cs_")); !os.IsNotExist(err) {
+			t.Errorf("検証エラー時に出力ファイルが作成されています")
+		}
+	})
+
+	t.Run("想定ファイルと一致する場合は通常どおり処理", func(t *testing.T) {
+		tempDir := t.TempDir()
+		content := "\"2024-02-28\",\"24:30\"\r\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "INS_01.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		processor := &Processor{Logger: logger, ExpectedFiles: []string{"INS_01.csv"}}
+		replaced, err := processor.ProcessDirectory(tempDir)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !replaced {
+			t.Errorf("replaced = false, want true")
+		}
+	})
+}
+
+func TestProcessDirectoryStats(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"a.csv":    "\"2024-02-28\",\"24:30\"\r\n\"2024-02-28\",\"12:00\"\r\n\"2024-02-28\",\"47:00\"\r\n",
+		"b.CSV":    "\"2024-02-28\",\"12:00\"\r\n",
+		"memo.txt": "\"2024-02-28\",\"24:30\"\r\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tempDir, "sub.csv"), 0755); err != nil {
+		t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: logger}
+	if _, err := processor.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	want := Stats{
+		FilesProcessed: 2,
+		FilesReplaced:  1,
+		FilesSkipped:   1,
+		RowsRead:       4,
+		RowsReplaced:   2,
+	}
+	if processor.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", processor.Stats, want)
+	}
+}
+
+func TestProcessDirectoryStatsDoneDirFailure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "a.csv"), []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	// 移動先がディレクトリではないため、変換後の移動に失敗する
+	doneDir := filepath.Join(t.TempDir(), "done")
+	if err := os.WriteFile(doneDir, nil, 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: logger, DoneDir: doneDir, RunID: "run1", KeepGoing: true}
+	if _, err := processor.ProcessDirectory(tempDir); err == nil {
+		t.Errorf("エラーが返るべきです")
+	}
+
+	want := Stats{FilesFailed: 1}
+	if processor.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", processor.Stats, want)
+	}
+	if len(processor.Results) != 1 || processor.Results[0].Status != FileFailed {
+		t.Errorf("Results = %+v, 失敗のみが記録されるべきです", processor.Results)
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rootDir := t.TempDir()
+
+	files := map[string]string{
+		"131016/a.csv": "\"2024-02-28\",\"24:30\"\r\n",
+		"271004/a.csv": "\"2024-02-28\",\"12:00\"\r\n\"2024-02-28\",\"13:00\"\r\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(rootDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	// ディレクトリ単位の検証エラーは他のディレクトリの処理を止めない
+	if err := os.Mkdir(filepath.Join(rootDir, "400009"), 0755); err != nil {
+		t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: logger, ExpectedFiles: []string{"a.csv"}}
+	results, err := processor.ProcessBatch(rootDir)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("len(results) = %d, want 3", len(results))
+	}
+
+	if r := results[0]; !r.Replaced || r.Err != nil || r.Stats.FilesReplaced != 1 {
+		t.Errorf("131016: %+v, 置換ありで成功すべきです", r)
+	}
+	if r := results[1]; r.Replaced || r.Err != nil || r.Stats.RowsRead != 2 {
+		t.Errorf("271004: %+v, 置換なしで成功すべきです", r)
+	}
+	if r := results[2]; r.Err == nil {
+		t.Errorf("400009: %+v, ファイル不足のエラーになるべきです", r)
+	}
+
+	if processor.Stats.FilesProcessed != 2 || processor.Stats.RowsRead != 3 {
+		t.Errorf("Stats = %+v, 全ディレクトリの合計になるべきです", processor.Stats)
+	}
+}
+
+func TestProcessDirectoryKeepGoing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	setup := func(t *testing.T) string {
+		tempDir := t.TempDir()
+		// リンク先が存在しないため、オープン時にエラーとなるCSV
+		if err := os.Symlink(filepath.Join(tempDir, "missing"), filepath.Join(tempDir, "a.csv")); err != nil {
+			t.Skipf("シンボリックリンクを作成できません: %v", err)
+		}
+		content := "\"2024-02-28\",\"24:30\"\r\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "b.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		return tempDir
+	}
+
+	t.Run("既定では最初のエラーで中断する", func(t *testing.T) {
+		tempDir := setup(t)
+		processor := &Processor{Logger: logger}
+		if _, err := processor.ProcessDirectory(tempDir); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "b.cs_")); !os.IsNotExist(err) {
+			t.Errorf("中断後のファイルが処理されています")
+		}
+	})
+
+	t.Run("KeepGoing では残りのファイルを処理してからエラーを返す", func(t *testing.T) {
+		tempDir := setup(t)
+		processor := &Processor{Logger: logger, KeepGoing: true}
+		replaced, err := processor.ProcessDirectory(tempDir)
+		if err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+		if !replaced {
+			t.Errorf("replaced = false, want true")
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "b.cs_")); err != nil {
+			t.Errorf("後続のファイルが処理されていません: %v", err)
+		}
+		if processor.Stats.FilesFailed != 1 || processor.Stats.FilesProcessed != 1 {
+			t.Errorf("Stats = %+v, FilesFailed=1, FilesProcessed=1 であるべきです", processor.Stats)
+		}
+	})
+}
+
+func TestProcessDirectoryRetry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// 最初の failures 回のオープンが一時的なエラーになるよう openFile を差し替える
+	stubOpen := func(t *testing.T, failures int) *int {
+		calls := 0
+		orig := openFile
+		openFile = func(name string) (io.ReadCloser, error) {
+			calls++
+			if calls <= failures {
+				return nil, errors.New("一時的なネットワークエラー")
+			}
+			return orig(name)
+		}
+		t.Cleanup(func() { openFile = orig })
+		return &calls
+	}
+
+	setup := func(t *testing.T) string {
+		tempDir := t.TempDir()
+		content := "\"2024-02-28\",\"24:30\"\r\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "a.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		return tempDir
+	}
+
+	t.Run("再試行回数内に回復すれば成功", func(t *testing.T) {
+		tempDir := setup(t)
+		calls := stubOpen(t, 2)
+		processor := &Processor{Logger: logger, Retries: 2, RetryDelay: time.Millisecond}
+		replaced, err := processor.ProcessDirectory(tempDir)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !replaced {
+			t.Errorf("replaced = false, want true")
+		}
+		if *calls != 3 {
+			t.Errorf("オープン回数 = %d, want 3", *calls)
+		}
+	})
+
+	t.Run("読み込み途中のエラーでは途中までの結果を捨てて先頭から読み直す", func(t *testing.T) {
+		tempDir := t.TempDir()
+		content := "id,date,time\r\n\"1\",\"2024-02-28\",\"24:30\"\r\n\"2\",\"2024-02-28\",\"25:00\"\r\n"
+		if err := os.WriteFile(filepath.Join(tempDir, "a.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		// 1回目は2行目の途中まで読めたところで接続が切れる
+		calls := 0
+		orig := openFile
+		openFile = func(name string) (io.ReadCloser, error) {
+			calls++
+			f, err := orig(name)
+			if err != nil || calls > 1 {
+				return f, err
+			}
+			partial := io.MultiReader(io.LimitReader(f, 30), iotest.ErrReader(errors.New("接続がリセットされました")))
+			return struct {
+				io.Reader
+				io.Closer
+			}{partial, f}, nil
+		}
+		t.Cleanup(func() { openFile = orig })
+
+		processor := &Processor{Logger: logger, Retries: 1, RetryDelay: time.Millisecond}
+		if _, err := processor.ProcessDirectory(tempDir); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("オープン回数 = %d, want 2", calls)
+		}
+		outData, err := os.ReadFile(filepath.Join(tempDir, "a.cs_"))
+		if err != nil {
+			t.Fatalf("出力ファイルが作成されていません: %v", err)
+		}
+		expected := "id,date,time\r\n\"1\",\"2024-02-29\",\"00:30\"\r\n\"2\",\"2024-02-29\",\"01:00\"\r\n"
+		if string(outData) != expected {
+			t.Errorf("生成ファイル内容:\n%v\n想定内容:\n%v", string(outData), expected)
+		}
+		want := Stats{FilesProcessed: 1, FilesReplaced: 1, RowsRead: 3, RowsReplaced: 2}
+		if processor.Stats != want {
+			t.Errorf("Stats = %+v, want %+v", processor.Stats, want)
+		}
+	})
+
+	t.Run("再試行回数を超えたらエラー", func(t *testing.T) {
+		tempDir := setup(t)
+		calls := stubOpen(t, 3)
+		processor := &Processor{Logger: logger, Retries: 2, RetryDelay: time.Millisecond}
+		if _, err := processor.ProcessDirectory(tempDir); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+		if *calls != 3 {
+			t.Errorf("オープン回数 = %d, want 3", *calls)
+		}
+	})
+}
+
+func TestProcessDirectoryMinAge(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	content := "\"2024-02-28\",\"24:30\"\r\n"
+	for _, name := range []string{"old.csv", "new.csv"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(tempDir, "old.csv"), old, old); err != nil {
+		t.Fatalf("更新日時の変更に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: logger, MinAge: 2 * time.Minute}
+	if _, err := processor.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "old.cs_")); err != nil {
+		t.Errorf("十分に古いファイルが処理されていません: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "new.cs_")); !os.IsNotExist(err) {
+		t.Errorf("更新直後のファイルが処理されています")
+	}
+	if processor.Stats.FilesDeferred != 1 || processor.Stats.FilesProcessed != 1 {
+		t.Errorf("Stats = %+v, FilesDeferred=1, FilesProcessed=1 であるべきです", processor.Stats)
+	}
+}
+
+func TestProcessDirectoryDoneDir(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	doneDir := t.TempDir()
+
+	files := map[string]string{
+		"a.csv": "\"2024-02-28\",\"24:30\"\r\n",
+		"b.csv": "\"2024-02-28\",\"12:00\"\r\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	processor := &Processor{Logger: logger, DoneDir: doneDir}
+	if _, err := processor.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	for name, content := range files {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s が移動されていません", name)
+		}
+		got, err := os.ReadFile(filepath.Join(doneDir, name))
+		if err != nil {
+			t.Errorf("%s が移動先に存在しません: %v", name, err)
+		} else if string(got) != content {
+			t.Errorf("%s の内容が変わっています: %q", name, got)
+		}
+	}
+	// 出力ファイルは移動せず元のディレクトリに残す
+	if _, err := os.Stat(filepath.Join(tempDir, "a.cs_")); err != nil {
+		t.Errorf("出力ファイルが元のディレクトリにありません: %v", err)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	t.Run("移動先に存在しなければ移動する", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dest := filepath.Join(dir, "a.csv"), filepath.Join(dir, "done.csv")
+		if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		if err := moveFile(src, dest); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("移動元のファイルが残っています")
+		}
+		if got, _ := os.ReadFile(dest); string(got) != "new" {
+			t.Errorf("移動先の内容 = %q, want %q", got, "new")
+		}
+	})
+
+	t.Run("移動先に同名のファイルがあれば上書きしない", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dest := filepath.Join(dir, "a.csv"), filepath.Join(dir, "done.csv")
+		if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		if err := os.WriteFile(dest, []byte("archived"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		err := moveFile(src, dest)
+		if err == nil || !strings.Contains(err.Error(), "既に存在します") {
+			t.Errorf("err = %v, 既存ファイルがある場合はエラーになるべきです", err)
+		}
+		if got, _ := os.ReadFile(dest); string(got) != "archived" {
+			t.Errorf("移動先の内容 = %q, 既存ファイルが上書きされています", got)
+		}
+		if got, _ := os.ReadFile(src); string(got) != "new" {
+			t.Errorf("移動元の内容 = %q, 移動元が失われています", got)
+		}
+	})
+}
+
+func TestProcessDirectoryDoneDirCollision(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("毎日同じ名前で届くファイルを実行ごとに移動する", func(t *testing.T) {
+		tempDir := t.TempDir()
+		doneDir := t.TempDir()
+		for _, runID := range []string{"run1", "run2"} {
+			if err := os.WriteFile(filepath.Join(tempDir, "INS_01.csv"), []byte(runID), 0644); err != nil {
+				t.Fatalf("テストファイルの作成に失敗: %v", err)
+			}
+			processor := &Processor{Logger: logger, DoneDir: doneDir, RunID: runID}
+			if _, err := processor.ProcessDirectory(tempDir); err != nil {
+				t.Fatalf("%s: 予期せぬエラー: %v", runID, err)
+			}
+		}
+		for _, runID := range []string{"run1", "run2"} {
+			if got, _ := os.ReadFile(filepath.Join(doneDir, runID, "INS_01.csv")); string(got) != runID {
+				t.Errorf("%s の移動先の内容 = %q, want %q", runID, got, runID)
+			}
+		}
+	})
+
+	t.Run("バッチ処理ではサブディレクトリごとに移動する", func(t *testing.T) {
+		rootDir := t.TempDir()
+		doneDir := t.TempDir()
+		for _, sub := range []string{"a", "b"} {
+			if err := os.Mkdir(filepath.Join(rootDir, sub), 0755); err != nil {
+				t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(rootDir, sub, "INS_01.csv"), []byte(sub), 0644); err != nil {
+				t.Fatalf("テストファイルの作成に失敗: %v", err)
+			}
+		}
+
+		processor := &Processor{Logger: logger, DoneDir: doneDir, RunID: "run1"}
+		results, err := processor.ProcessBatch(rootDir)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				t.Errorf("%s: 予期せぬエラー: %v", r.Dir, r.Err)
+			}
+		}
+		for _, sub := range []string{"a", "b"} {
+			if got, _ := os.ReadFile(filepath.Join(doneDir, "run1", sub, "INS_01.csv")); string(got) != sub {
+				t.Errorf("%s の移動先の内容 = %q, want %q", sub, got, sub)
+			}
+		}
+	})
+}
+
+func TestProcessDirectoryErrorDir(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	errorDir := t.TempDir()
+
+	content := "\"2024-02-28\",\"24:30\"\r\n"
+	for _, name := range []string{"bad.csv", "good.csv"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	orig := openFile
+	openFile = func(name string) (io.ReadCloser, error) {
+		if filepath.Base(name) == "bad.csv" {
+			return nil, errors.New("読み込み不能")
+		}
+		return orig(name)
+	}
+	t.Cleanup(func() { openFile = orig })
+
+	processor := &Processor{Logger: logger, KeepGoing: true, ErrorDir: errorDir}
+	if _, err := processor.ProcessDirectory(tempDir); err == nil {
+		t.Errorf("エラーが返るべきです")
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "bad.csv")); !os.IsNotExist(err) {
+		t.Errorf("失敗したファイルが移動されていません")
+	}
+	if _, err := os.Stat(filepath.Join(errorDir, "bad.csv")); err != nil {
+		t.Errorf("失敗したファイルが移動先に存在しません: %v", err)
+	}
+	note, err := os.ReadFile(filepath.Join(errorDir, "bad.csv.error.txt"))
+	if err != nil {
+		t.Fatalf("エラー内容のファイルが作成されていません: %v", err)
+	}
+	if !strings.Contains(string(note), "読み込み不能") {
+		t.Errorf("エラー内容 = %q, 原因が記載されていません", note)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "good.csv")); err != nil {
+		t.Errorf("成功したファイルは移動しないはずです: %v", err)
+	}
+}
+
+func TestProcessDirectoryErrorDirAfterConversion(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	errorDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "INS_01.csv"), []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	// 移動先がディレクトリではないため、変換後の移動に失敗する
+	doneDir := filepath.Join(t.TempDir(), "done")
+	if err := os.WriteFile(doneDir, nil, 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: logger, DoneDir: doneDir, ErrorDir: errorDir}
+	if _, err := processor.ProcessDirectory(tempDir); err == nil {
+		t.Errorf("エラーが返るべきです")
+	}
+	if _, err := os.Stat(filepath.Join(errorDir, "INS_01.csv")); err != nil {
+		t.Errorf("失敗したファイルが移動先に存在しません: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "INS_01.cs_")); !os.IsNotExist(err) {
+		t.Errorf("失敗したファイルの出力が入力ディレクトリに残っています")
+	}
+}
+
+func TestProcessDirectoryErrorDirCollision(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	errorDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "bad.csv"), []byte("new"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	// 前回の実行で失敗した同名のファイルとエラー内容が残っている
+	previous := map[string]string{"bad.csv": "previous", "bad.csv.error.txt": "前回のエラー\r\n"}
+	for name, content := range previous {
+		if err := os.WriteFile(filepath.Join(errorDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	orig := openFile
+	openFile = func(name string) (io.ReadCloser, error) {
+		return nil, errors.New("読み込み不能")
+	}
+	t.Cleanup(func() { openFile = orig })
+
+	processor := &Processor{Logger: logger, ErrorDir: errorDir}
+	if _, err := processor.ProcessDirectory(tempDir); err == nil {
+		t.Errorf("エラーが返るべきです")
+	}
+	for name, content := range previous {
+		if got, _ := os.ReadFile(filepath.Join(errorDir, name)); string(got) != content {
+			t.Errorf("%s = %q, 前回のファイルが上書きされています", name, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "bad.csv")); err != nil {
+		t.Errorf("移動できなかったファイルが元のディレクトリに残っていません: %v", err)
+	}
+}
+
+// withoutDurations は実行ごとに変わる処理時間を除いた結果を返します。
+func withoutDurations(results []FileResult) []FileResult {
+	out := make([]FileResult, len(results))
+	for i, r := range results {
+		r.DurationSec = 0
+		out[i] = r
+	}
+	return out
+}
+
+func TestProcessDirectoryResults(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"a.csv": "\"2024-02-28\",\"24:30\"\r\n\"2024-02-28\",\"25:30\"\r\n",
+		"b.csv": "\"2024-02-28\",\"12:00\"\r\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	processor := &Processor{Logger: logger}
+	if _, err := processor.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	want := []FileResult{
+		{Path: filepath.Join(tempDir, "a.csv"), Status: FileReplaced, ReplaceCount: 2},
+		{Path: filepath.Join(tempDir, "b.csv"), Status: FileUnchanged},
+	}
+	if got := withoutDurations(processor.Results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Results = %+v, want %+v", got, want)
+	}
+	for _, r := range processor.Results {
+		if r.DurationSec <= 0 {
+			t.Errorf("%s: DurationSec = %v, 処理時間が記録されていません", r.Path, r.DurationSec)
+		}
+	}
+}
+
+func TestProcessFiles(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"listed.csv":   "\"2024-02-28\",\"24:30\"\r\n",
+		"unlisted.csv": "\"2024-02-28\",\"24:30\"\r\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	processor := &Processor{Logger: logger}
+	replaced, err := processor.ProcessFiles([]string{filepath.Join(tempDir, "listed.csv")})
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if !replaced {
+		t.Errorf("replaced = false, want true")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "listed.cs_")); err != nil {
+		t.Errorf("指定したファイルが処理されていません: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "unlisted.cs_")); !os.IsNotExist(err) {
+		t.Errorf("指定していないファイルが処理されています")
+	}
+}
+
+func TestProcessFilesSingleFile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	content := "\"2024-02-28\",\"24:30\"\r\n"
+	for _, name := range []string{"INS_01.csv", "INS_02.csv", "UPD_01.csv"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+	}
+
+	// -file INS_02.csv と同じ呼び出し
+	processor := &Processor{Logger: logger}
+	replaced, err := processor.ProcessFiles([]string{filepath.Join(tempDir, "INS_02.csv")})
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if !replaced {
+		t.Errorf("replaced = false, want true")
+	}
+
+	want := Stats{FilesProcessed: 1, FilesReplaced: 1, RowsRead: 1, RowsReplaced: 1}
+	if processor.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", processor.Stats, want)
+	}
+	outData, err := os.ReadFile(filepath.Join(tempDir, "INS_02.cs_"))
+	if err != nil {
+		t.Fatalf("指定したファイルが処理されていません: %v", err)
+	}
+	if expected := "\"2024-02-29\",\"00:30\"\r\n"; string(outData) != expected {
+		t.Errorf("生成ファイル内容 = %q, want %q", outData, expected)
+	}
+	for _, name := range []string{"INS_01", "UPD_01"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name+".cs_")); !os.IsNotExist(err) {
+			t.Errorf("指定していない %s.csv が処理されています", name)
+		}
+		got, err := os.ReadFile(filepath.Join(tempDir, name+".csv"))
+		if err != nil || string(got) != content {
+			t.Errorf("指定していない %s.csv が変更されています: %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestProcessDirectoryStreamListing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	orig := listPageSize
+	listPageSize = 2
+	t.Cleanup(func() { listPageSize = orig })
+
+	setup := func(t *testing.T) string {
+		tempDir := t.TempDir()
+		for i := range 5 {
+			content := "\"2024-02-28\",\"24:30\"\r\n"
+			name := filepath.Join(tempDir, "INS_"+strconv.Itoa(i)+".csv")
+			if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+				t.Fatalf("テストファイルの作成に失敗: %v", err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, "memo.txt"), nil, 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		return tempDir
+	}
+
+	t.Run("ページをまたいで全ファイルを処理する", func(t *testing.T) {
+		tempDir := setup(t)
+		processor := &Processor{Logger: logger, StreamListing: true}
+		replaced, err := processor.ProcessDirectory(tempDir)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !replaced {
+			t.Errorf("replaced = false, want true")
+		}
+		want := Stats{FilesProcessed: 5, FilesReplaced: 5, FilesSkipped: 1, RowsRead: 5, RowsReplaced: 5}
+		if processor.Stats != want {
+			t.Errorf("Stats = %+v, want %+v", processor.Stats, want)
+		}
+	})
+
+	t.Run("ページ数を大きく超えても各CSVを1回ずつ処理する", func(t *testing.T) {
+		tempDir := t.TempDir()
+		const n = 50
+		for i := range n {
+			// 置換対象を含むため、一覧の取得中に同じディレクトリへ .cs_ が作成されていく
+			name := filepath.Join(tempDir, fmt.Sprintf("INS_%03d.csv", i))
+			if err := os.WriteFile(name, []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+				t.Fatalf("テストファイルの作成に失敗: %v", err)
+			}
+		}
+
+		processor := &Processor{Logger: logger, StreamListing: true}
+		if _, err := processor.ProcessDirectory(tempDir); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		seen := make(map[string]int)
+		for _, r := range processor.Results {
+			seen[filepath.Base(r.Path)]++
+		}
+		for i := range n {
+			name := fmt.Sprintf("INS_%03d.csv", i)
+			if seen[name] != 1 {
+				t.Errorf("%s の処理回数 = %d, want 1", name, seen[name])
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("処理したファイル = %d種類, want %d", len(seen), n)
+		}
+		if processor.Stats.FilesSkipped != 0 {
+			t.Errorf("FilesSkipped = %d, 出力した .cs_ を数えています", processor.Stats.FilesSkipped)
+		}
+	})
+
+	t.Run("途中のエラーで中断できる", func(t *testing.T) {
+		tempDir := setup(t)
+		orig := openFile
+		openFile = func(name string) (io.ReadCloser, error) {
+			return nil, errors.New("読み込み不能")
+		}
+		t.Cleanup(func() { openFile = orig })
+
+		processor := &Processor{Logger: logger, StreamListing: true}
+		if _, err := processor.ProcessDirectory(tempDir); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+		if processor.Stats.FilesFailed != 1 {
+			t.Errorf("FilesFailed = %d, want 1", processor.Stats.FilesFailed)
+		}
+	})
+
+	t.Run("一覧全体を必要とする検証とは併用できない", func(t *testing.T) {
+		tempDir := setup(t)
+		processor := &Processor{Logger: logger, StreamListing: true, CheckSequence: true}
+		if _, err := processor.ProcessDirectory(tempDir); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+	})
+}
+
+func TestProcessDirectoryTimeWindow(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	mtimes := map[string]time.Time{
+		"before.csv": base.Add(-48 * time.Hour),
+		"inside.csv": base,
+		"after.csv":  base.Add(48 * time.Hour),
+	}
+	for name, mtime := range mtimes {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("更新日時の変更に失敗: %v", err)
+		}
+	}
+
+	processor := &Processor{
+		Logger: logger,
+		Since:  base.Add(-24 * time.Hour),
+		Until:  base.Add(24 * time.Hour),
+	}
+	if _, err := processor.ProcessDirectory(tempDir); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	for name := range mtimes {
+		_, err := os.Stat(filepath.Join(tempDir, strings.TrimSuffix(name, ".csv")+".cs_"))
+		if processed := err == nil; processed != (name == "inside.csv") {
+			t.Errorf("%s: processed = %v", name, processed)
+		}
+	}
+	if processor.Stats.FilesSkipped != 2 {
+		t.Errorf("FilesSkipped = %d, want 2", processor.Stats.FilesSkipped)
+	}
+}
+
+func TestProcessDirectoryIncremental(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	journalPath := filepath.Join(t.TempDir(), "journal.json")
+
+	content := "\"2024-02-28\",\"24:30\"\r\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "a.csv"), []byte(content), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	runOnce := func(t *testing.T) *Processor {
+		journal, err := LoadJournal(journalPath)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		processor := &Processor{Logger: logger, Journal: journal}
+		if _, err := processor.ProcessDirectory(tempDir); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if err := journal.Save(); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		return processor
+	}
+
+	if p := runOnce(t); p.Stats.FilesProcessed != 1 {
+		t.Errorf("1回目: FilesProcessed = %d, want 1", p.Stats.FilesProcessed)
+	}
+
+	// 変更がなければ2回目はスキップされる
+	if p := runOnce(t); p.Stats.FilesProcessed != 0 {
+		t.Errorf("2回目: Stats = %+v, 処理済みファイルはスキップされるべきです", p.Stats)
+	}
+
+	// 新しいファイルのみ処理される
+	if err := os.WriteFile(filepath.Join(tempDir, "b.csv"), []byte(content), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	p := runOnce(t)
+	want := []FileResult{{Path: filepath.Join(tempDir, "b.csv"), Status: FileReplaced, ReplaceCount: 1}}
+	if got := withoutDurations(p.Results); !reflect.DeepEqual(got, want) {
+		t.Errorf("3回目: Results = %+v, 新しいファイルのみ処理されるべきです", got)
+	}
+
+	// 出力ファイルが削除されていれば、入力に変更がなくても再作成する
+	if err := os.Remove(filepath.Join(tempDir, "a.cs_")); err != nil {
+		t.Fatalf("出力ファイルの削除に失敗: %v", err)
+	}
+	if p := runOnce(t); p.Stats.FilesProcessed != 1 {
+		t.Errorf("4回目: FilesProcessed = %d, want 1", p.Stats.FilesProcessed)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "a.cs_")); err != nil {
+		t.Errorf("削除された出力ファイルが再作成されていません: %v", err)
+	}
+}
+
+func TestProcessDirectoryIncrementalDoneDirFailure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	doneDir := t.TempDir()
+	srcPath := filepath.Join(tempDir, "a.csv")
+	if err := os.WriteFile(srcPath, []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	// 同名のファイルが移動先にあるため移動に失敗する
+	if err := os.WriteFile(filepath.Join(doneDir, "a.csv"), []byte("archived"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	journal, err := LoadJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	processor := &Processor{Logger: logger, Journal: journal, DoneDir: doneDir}
+	if _, err := processor.ProcessDirectory(tempDir); err == nil {
+		t.Errorf("エラーが返るべきです")
+	}
+	if len(journal.Entries) != 0 {
+		t.Errorf("Entries = %v, 移動に失敗したファイルが処理済みとして記録されています", journal.Entries)
+	}
+}
+
+func TestProcessDirectoryLongLine(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// 数MBの備考欄を持つ1行（bufio.Scanner の既定上限 64KB を大きく超える）
+	remark := strings.Repeat("あ", 1<<20) // 約3MB
+	content := "id,remark,date,time\r\n\"1\",\"" + remark + "\",\"2024-02-28\",\"24:30\"\r\n"
+
+	t.Run("上限内であれば長い行も置換される", func(t *testing.T) {
+		tempDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tempDir, "long.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		processor := &Processor{Logger: logger}
+		replaced, err := processor.ProcessDirectory(tempDir)
+		if err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !replaced {
+			t.Errorf("replaced = false, want true")
+		}
+
+		outData, err := os.ReadFile(filepath.Join(tempDir, "long.cs_"))
+		if err != nil {
+			t.Fatalf("出力ファイルが作成されていません: %v", err)
+		}
+		want := "id,remark,date,time\r\n\"1\",\"" + remark + "\",\"2024-02-29\",\"00:30\"\r\n"
+		if string(outData) != want {
+			t.Errorf("生成ファイルの長さ = %d, want %d", len(outData), len(want))
+		}
+	})
+
+	t.Run("上限を超える行はエラー", func(t *testing.T) {
+		tempDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(tempDir, "long.csv"), []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		processor := &Processor{Logger: logger, MaxLineSize: 1 << 20, Retries: 3}
+		_, err := processor.ProcessDirectory(tempDir)
+		if err == nil {
+			t.Fatal("エラーが返るべきです")
+		}
+		if !errors.Is(err, bufio.ErrTooLong) {
+			t.Errorf("err = %v, want bufio.ErrTooLong", err)
+		}
+		if want := "2行目が上限 1048576 バイトを超えています"; !strings.Contains(err.Error(), want) {
+			t.Errorf("エラーメッセージ %q に %q が含まれていません", err.Error(), want)
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "long.cs_")); !os.IsNotExist(err) {
+			t.Errorf("エラー時に出力ファイルが作成されています")
+		}
+	})
+}
+
+// BenchmarkScanFileClean は置換対象のない行のみからなるファイルの変換速度を計測します。
+func BenchmarkScanFileClean(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "clean.csv")
+	var sb strings.Builder
+	sb.WriteString("id,name,remark,date,time\r\n")
+	for i := range 10000 {
+		sb.WriteString(`"` + strconv.Itoa(i) + `","山田　太郎","備考欄のテキスト","2024-02-28","12:30"` + "\r\n")
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	processor := &Processor{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	b.SetBytes(int64(sb.Len()))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := processor.scanFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Chdir(tempDir)
+	path := filepath.Join("sub", "INS_01.csv")
+	abs := filepath.Join(tempDir, path)
+
+	tests := []struct {
+		name  string
+		style PathStyle
+		input string
+		want  string
+	}{
+		{"未指定は指定されたパスのまま", "", abs, abs},
+		{"作業ディレクトリからの相対パス", PathRelative, abs, path},
+		{"絶対パス", PathAbsolute, path, abs},
+		{"ファイル名のみ", PathBase, abs, "INS_01.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{ShowPath: tt.style}
+			if got := p.displayPath(tt.input); got != tt.want {
+				t.Errorf("displayPath(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("移動先のパスにも表記を適用する", func(t *testing.T) {
+		srcDir := filepath.Join(tempDir, "in")
+		doneDir := filepath.Join(tempDir, "done")
+		for _, d := range []string{srcDir, doneDir} {
+			if err := os.Mkdir(d, 0755); err != nil {
+				t.Fatalf("テストディレクトリの作成に失敗: %v", err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, "a.csv"), []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗: %v", err)
+		}
+
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		p := &Processor{Logger: logger, ShowPath: PathBase, DoneDir: doneDir}
+		if _, err := p.ProcessDirectory(srcDir); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		if !strings.Contains(buf.String(), "dest=a.csv") {
+			t.Errorf("ログ %q に移動先のファイル名のみが出力されていません", buf.String())
+		}
+		if strings.Contains(buf.String(), tempDir) {
+			t.Errorf("ログ %q にファイル名以外のパスが含まれています", buf.String())
+		}
+	})
+
+	t.Run("不正な値はエラー", func(t *testing.T) {
+		var style PathStyle
+		if err := style.Set("full"); err == nil {
+			t.Errorf("エラーが返るべきです")
+		}
+	})
+}
+
+func TestProcessFilesKeepsOutputs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	csvPath := filepath.Join(tempDir, "a.csv")
+	outPath := filepath.Join(tempDir, "a.cs_")
+	// 前回の出力が残っている状態。a.csv は置換対象を含まないため a.cs_ は再作成されない
+	if err := os.WriteFile(csvPath, []byte("\"2024-02-28\",\"12:30\"\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	output := "\"2024-02-29\",\"00:30\"\r\n"
+	if err := os.WriteFile(outPath, []byte(output), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+	assertOutputIntact := func(t *testing.T) {
+		t.Helper()
+		got, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("出力ファイルを読み込めません: %v", err)
+		}
+		if string(got) != output {
+			t.Errorf("a.cs_ = %q, 既存の出力ファイルが上書きされています", got)
+		}
+	}
+
+	t.Run("ワイルドカードの展開で出力ファイルを含めない", func(t *testing.T) {
+		_, files := classifyArgs([]string{filepath.Join(tempDir, "*")})
+		if !reflect.DeepEqual(files, []string{csvPath}) {
+			t.Fatalf("files = %v, want [%s]", files, csvPath)
+		}
+		processor := &Processor{Logger: logger}
+		if _, err := processor.ProcessFiles(files); err != nil {
+			t.Fatalf("予期せぬエラー: %v", err)
+		}
+		assertOutputIntact(t)
+	})
+
+	t.Run("出力ファイルを直接指定した場合はエラー", func(t *testing.T) {
+		processor := &Processor{Logger: logger}
+		_, err := processor.ProcessFiles([]string{outPath})
+		if err == nil || !strings.Contains(err.Error(), "出力ファイルが入力ファイルと同じパス") {
+			t.Errorf("err = %v, 同じパスへの出力はエラーになるべきです", err)
+		}
+		assertOutputIntact(t)
+	})
+}
+
+// failingCloser はクローズ時にエラーを返すファイルです。
+type failingCloser struct {
+	*os.File
+}
+
+func (f failingCloser) Close() error {
+	f.File.Close()
+	return errors.New("ディスクの空き容量がありません")
+}
+
+func TestProcessDirectoryWriteFailure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "a.csv"), []byte("\"2024-02-28\",\"24:30\"\r\n"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	orig := createFile
+	createFile = func(name string) (io.WriteCloser, error) {
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		return failingCloser{f}, nil
+	}
+	t.Cleanup(func() { createFile = orig })
+
+	processor := &Processor{Logger: logger}
+	_, err := processor.ProcessDirectory(tempDir)
+	if err == nil || !strings.Contains(err.Error(), "ディスクの空き容量がありません") {
+		t.Errorf("err = %v, クローズのエラーが返るべきです", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "a.cs_")); !os.IsNotExist(err) {
+		t.Errorf("失敗した出力ファイルが残っています")
+	}
+	if processor.Stats.FilesReplaced != 0 {
+		t.Errorf("FilesReplaced = %d, want 0", processor.Stats.FilesReplaced)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // http.DefaultServeMux に /debug/pprof/ を登録する
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profileOptions はプロファイリングの設定です。
+type profileOptions struct {
+	pprofAddr  string // /debug/pprof/ を公開するアドレス（例: localhost:6060）
+	cpuProfile string // CPUプロファイルの出力先
+	memProfile string // 終了時のヒーププロファイルの出力先
+}
+
+// startProfiling は指定されたプロファイリングを開始し、終了時に呼び出す関数を返します。
+// 返された関数はCPUプロファイルを停止し、ヒーププロファイルを書き出します。
+func startProfiling(opts profileOptions, logger *slog.Logger) (func() error, error) {
+	if opts.pprofAddr != "" {
+		// 待ち受けの失敗は起動時に検出できるよう、Listen は同期的に行う
+		addr, allInterfaces := pprofListenAddr(opts.pprofAddr)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("pprof待ち受けエラー: %w", err)
+		}
+		logger.Info("pprofを公開しました", "addr", ln.Addr().String())
+		if allInterfaces {
+			logger.Warn("pprofを全てのネットワークインターフェースに公開しています。ヒープやゴルーチンの内容が外部から参照できます", "addr", ln.Addr().String())
+		}
+		go func() {
+			if err := http.Serve(ln, nil); err != nil {
+				logger.Warn("pprofの公開を終了しました", "error", err)
+			}
+		}()
+	}
+
+	var cpuFile *os.File
+	if opts.cpuProfile != "" {
+		f, err := os.Create(opts.cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("CPUプロファイル作成エラー: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("CPUプロファイル開始エラー: %w", err)
+		}
+		cpuFile = f
+	}
+
+	stop := func() error {
+		var errs []error
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("CPUプロファイル書き込みエラー: %w", err))
+			}
+		}
+		if opts.memProfile != "" {
+			if err := writeHeapProfile(opts.memProfile); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return stop, nil
+}
+
+// pprofListenAddr は -pprof の指定値から待ち受けるアドレスを決めます。
+// ヒープやゴルーチンの内容を意図せずネットワークに公開しないよう、ホストを省略した場合（:6060 など）は
+// localhost で待ち受けます。0.0.0.0 などを明示した場合は、全インターフェースでの公開であることを返します。
+func pprofListenAddr(addr string) (string, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, false // 不正な値は Listen のエラーとして報告する
+	}
+	if host == "" {
+		return net.JoinHostPort("localhost", port), false
+	}
+	ip := net.ParseIP(host)
+	return addr, ip != nil && ip.IsUnspecified()
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("ヒーププロファイル作成エラー: %w", err)
+	}
+	// 最新の割り当て状況を反映させるため、書き出し前にGCを実行する
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("ヒーププロファイル書き込みエラー: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	opts := profileOptions{
+		cpuProfile: filepath.Join(dir, "cpu.pprof"),
+		memProfile: filepath.Join(dir, "mem.pprof"),
+	}
+
+	stop, err := startProfiling(opts, logger)
+	if err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	for _, path := range []string{opts.cpuProfile, opts.memProfile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("%s が作成されていません: %v", path, err)
+			continue
+		}
+		if info.Size() == 0 {
+			t.Errorf("%s が空です", path)
+		}
+	}
+}
+
+func TestPprofListenAddr(t *testing.T) {
+	tests := []struct {
+		name              string
+		addr              string
+		want              string
+		wantAllInterfaces bool
+	}{
+		{"ホスト省略時は localhost", ":6060", "localhost:6060", false},
+		{"localhost 指定", "localhost:6060", "localhost:6060", false},
+		{"IPv4 の全インターフェース", "0.0.0.0:6060", "0.0.0.0:6060", true},
+		{"IPv6 の全インターフェース", "[::]:6060", "[::]:6060", true},
+		{"特定のアドレス", "192.0.2.1:6060", "192.0.2.1:6060", false},
+		{"不正な値はそのまま", "6060", "6060", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, all := pprofListenAddr(tt.addr)
+			if got != tt.want || all != tt.wantAllInterfaces {
+				t.Errorf("pprofListenAddr(%q) = %q, %v, want %q, %v", tt.addr, got, all, tt.want, tt.wantAllInterfaces)
+			}
+		})
+	}
+}
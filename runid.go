@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"runtime/debug"
+	"time"
+)
+
+// version はビルド時に -ldflags "-X main.version=..." で埋め込むバージョンです。
+var version = ""
+
+// crockford はULIDで使用するCrockford's Base32の文字集合です。
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newRunID は実行を識別するためのULID（26文字）を生成します。
+// 先頭10文字がミリ秒単位の時刻を表すため、文字列の昇順が生成順になります。
+func newRunID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128ビットを先頭から5ビットずつ区切る（先頭の1文字のみ3ビット）
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// buildVersion は実行中のバイナリのバージョンを返します。
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
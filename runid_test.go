@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRunID(t *testing.T) {
+	now := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	id := newRunID(now)
+	if len(id) != 26 {
+		t.Fatalf("len(id) = %d, want 26", len(id))
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(crockford, c) {
+			t.Errorf("id %q に使用できない文字 %q が含まれています", id, c)
+		}
+	}
+
+	// 同じ時刻なら時刻部分は一致し、乱数部分は異なる
+	other := newRunID(now)
+	if id[:10] != other[:10] {
+		t.Errorf("時刻部分が一致しません: %q, %q", id, other)
+	}
+	if id == other {
+		t.Errorf("同じIDが生成されました: %q", id)
+	}
+
+	// 後の時刻で生成したIDは文字列として大きい
+	if later := newRunID(now.Add(time.Millisecond)); later <= id {
+		t.Errorf("後の時刻のID %q が %q 以下です", later, id)
+	}
+}
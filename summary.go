@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// RunInfo は実行を追跡するための識別情報です。
+type RunInfo struct {
+	RunID      string    `json:"run_id"`
+	Version    string    `json:"version"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// StatsRecord は機械処理向けに出力する1行分の集計結果です。
+type StatsRecord struct {
+	RunInfo
+	Stats
+	Usage    Usage `json:"usage"`
+	ExitCode int   `json:"exit_code"`
+}
+
+// WriteStatsJSON は集計結果を1行のJSONとして出力します。
+// path が "-" の場合は標準エラー出力に書き込み、それ以外はファイルを作成（上書き）します。
+func WriteStatsJSON(path string, record StatsRecord) error {
+	if path == "-" {
+		return writeStatsJSON(os.Stderr, record)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("出力ファイル作成エラー: %w", err)
+	}
+	if err := writeStatsJSON(f, record); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeStatsJSON(w io.Writer, record StatsRecord) error {
+	// json.Encoder は末尾に改行を付けるため、1レコード1行になる
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		return fmt.Errorf("書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// WriteBatchTable はバッチ処理のディレクトリごとの結果と合計を表形式で書き出します。
+// ディレクトリは style の表記で出力します。
+func WriteBatchTable(w io.Writer, results []DirResult, total Stats, style PathStyle) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "dir\tresult\tfiles_processed\tfiles_replaced\tfiles_failed\trows_read\trows_replaced")
+	for _, r := range results {
+		result := "unchanged"
+		switch {
+		case r.Err != nil:
+			result = "error"
+		case r.Replaced:
+			result = "replaced"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n", formatPath(style, r.Dir), result,
+			r.Stats.FilesProcessed, r.Stats.FilesReplaced, r.Stats.FilesFailed, r.Stats.RowsRead, r.Stats.RowsReplaced)
+	}
+	fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n", "total", "",
+		total.FilesProcessed, total.FilesReplaced, total.FilesFailed, total.RowsRead, total.RowsReplaced)
+	return tw.Flush()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteStatsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	stats := Stats{FilesProcessed: 3, FilesReplaced: 1, FilesSkipped: 2, RowsRead: 100, RowsReplaced: 5}
+
+	info := RunInfo{
+		RunID:      "01J0ABCDEFGHJKMNPQRSTVWXYZ",
+		Version:    "v1.2.0",
+		StartedAt:  time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC),
+		FinishedAt: time.Date(2024, 6, 1, 3, 5, 0, 0, time.UTC),
+	}
+
+	usage := Usage{WallTimeSec: 300, PeakRSSBytes: 1 << 20, TotalAllocBytes: 2 << 20, SysBytes: 3 << 20}
+
+	if err := WriteStatsJSON(path, StatsRecord{RunInfo: info, Stats: stats, Usage: usage, ExitCode: 1}); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ファイル %s が作成されていません: %v", path, err)
+	}
+	want := `{"run_id":"01J0ABCDEFGHJKMNPQRSTVWXYZ","version":"v1.2.0","started_at":"2024-06-01T03:00:00Z","finished_at":"2024-06-01T03:05:00Z","files_processed":3,"files_replaced":1,"files_skipped":2,"files_failed":0,"files_deferred":0,"rows_read":100,"rows_replaced":5,"usage":{"wall_time_sec":300,"peak_rss_bytes":1048576,"total_alloc_bytes":2097152,"sys_bytes":3145728},"exit_code":1}` + "\n"
+	if string(got) != want {
+		t.Errorf("出力内容:\n%v\n想定内容:\n%v", string(got), want)
+	}
+}
+
+func TestWriteBatchTable(t *testing.T) {
+	results := []DirResult{
+		{Dir: "131016", Replaced: true, Stats: Stats{FilesProcessed: 2, FilesReplaced: 1, RowsRead: 10, RowsReplaced: 3}},
+		{Dir: "271004", Stats: Stats{FilesProcessed: 1, RowsRead: 5}},
+		{Dir: "400009", Stats: Stats{FilesFailed: 1}, Err: errors.New("ファイル処理エラー")},
+	}
+	total := Stats{FilesProcessed: 3, FilesReplaced: 1, FilesFailed: 1, RowsRead: 15, RowsReplaced: 3}
+
+	var buf bytes.Buffer
+	if err := WriteBatchTable(&buf, results, total, ""); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+
+	want := `dir     result     files_processed  files_replaced  files_failed  rows_read  rows_replaced
+131016  replaced   2                1               0             10         3
+271004  unchanged  1                0               0             5          0
+400009  error      0                0               1             0          0
+total              3                1               1             15         3
+`
+	if buf.String() != want {
+		t.Errorf("出力内容:\n%v\n想定内容:\n%v", buf.String(), want)
+	}
+}
+
+func TestWriteBatchTableShowPath(t *testing.T) {
+	results := []DirResult{{Dir: filepath.Join("in", "root", "131016"), Stats: Stats{FilesProcessed: 1}}}
+
+	var buf bytes.Buffer
+	if err := WriteBatchTable(&buf, results, Stats{FilesProcessed: 1}, PathBase); err != nil {
+		t.Fatalf("予期せぬエラー: %v", err)
+	}
+	if strings.Contains(buf.String(), "root") || !strings.Contains(buf.String(), "\n131016 ") {
+		t.Errorf("出力内容:\n%v\nディレクトリ名のみが出力されるべきです", buf.String())
+	}
+}
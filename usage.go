@@ -0,0 +1,26 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// Usage は実行全体のリソース使用量です。
+type Usage struct {
+	WallTimeSec     float64 `json:"wall_time_sec"`
+	PeakRSSBytes    uint64  `json:"peak_rss_bytes"` // 取得できないOSでは0
+	TotalAllocBytes uint64  `json:"total_alloc_bytes"`
+	SysBytes        uint64  `json:"sys_bytes"` // GoランタイムがOSから確保したメモリ量
+}
+
+// collectUsage は開始・終了日時と現時点のメモリ統計からリソース使用量を集計します。
+func collectUsage(startedAt, finishedAt time.Time) Usage {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return Usage{
+		WallTimeSec:     finishedAt.Sub(startedAt).Seconds(),
+		PeakRSSBytes:    peakRSS(),
+		TotalAllocBytes: m.TotalAlloc,
+		SysBytes:        m.Sys,
+	}
+}
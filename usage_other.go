@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package main
+
+// peakRSS は最大常駐メモリサイズを取得できないOS向けの実装で、常に0を返します。
+func peakRSS() uint64 {
+	return 0
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCollectUsage(t *testing.T) {
+	startedAt := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC)
+	got := collectUsage(startedAt, startedAt.Add(90*time.Second))
+
+	if got.WallTimeSec != 90 {
+		t.Errorf("WallTimeSec = %v, want 90", got.WallTimeSec)
+	}
+	if got.TotalAllocBytes == 0 || got.SysBytes == 0 {
+		t.Errorf("メモリ統計が取得できていません: %+v", got)
+	}
+	if (runtime.GOOS == "linux" || runtime.GOOS == "windows") && got.PeakRSSBytes == 0 {
+		t.Errorf("PeakRSSBytes = 0, %s では取得できるはずです", runtime.GOOS)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetProcessMemoryInfo = syscall.NewLazyDLL("psapi.dll").NewProc("GetProcessMemoryInfo")
+
+// processMemoryCounters は PROCESS_MEMORY_COUNTERS 構造体に対応します。
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// peakRSS はプロセスの最大ワーキングセットサイズをバイト単位で返します。
+func peakRSS() uint64 {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	var c processMemoryCounters
+	c.cb = uint32(unsafe.Sizeof(c))
+	r, _, _ := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&c)), uintptr(c.cb))
+	if r == 0 {
+		return 0
+	}
+	return uint64(c.PeakWorkingSetSize)
+}